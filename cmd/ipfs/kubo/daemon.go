@@ -742,7 +742,7 @@ func serveHTTPApi(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, error
 	}
 
 	if len(cfg.Gateway.RootRedirect) > 0 {
-		opts = append(opts, corehttp.RedirectOption("", cfg.Gateway.RootRedirect))
+		opts = append(opts, corehttp.ExactRedirectOption("", cfg.Gateway.RootRedirect, http.StatusFound, corehttp.WithExternalRedirects()))
 	}
 
 	node, err := cctx.ConstructNode()
@@ -911,7 +911,7 @@ func serveHTTPGateway(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, e
 	}
 
 	if len(cfg.Gateway.RootRedirect) > 0 {
-		opts = append(opts, corehttp.RedirectOption("", cfg.Gateway.RootRedirect))
+		opts = append(opts, corehttp.ExactRedirectOption("", cfg.Gateway.RootRedirect, http.StatusFound, corehttp.WithExternalRedirects()))
 	}
 
 	node, err := cctx.ConstructNode()
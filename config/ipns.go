@@ -1,23 +1,194 @@
 package config
 
 import (
+	"fmt"
 	"math"
+	"net/url"
 	"time"
+
+	"github.com/ipfs/boxo/ipns"
 )
 
 const (
+	DefaultIpnsEnabled = true
+
 	DefaultIpnsMaxCacheTTL = time.Duration(math.MaxInt64)
+	DefaultIpnsMinCacheTTL = time.Duration(0)
+
+	DefaultIpnsMaxAcceptedLifetime = time.Duration(math.MaxInt64)
+
+	DefaultIpnsRepublishPeriod = 4 * time.Hour
+	DefaultIpnsRecordLifetime  = 48 * time.Hour
 )
 
 type Ipns struct {
+	// Enabled turns the whole IPNS subsystem (publishing, republishing and
+	// name resolution) on or off.
+	Enabled Flag `json:",omitempty"`
+
 	RepublishPeriod string
 	RecordLifetime  string
 
+	// PerKey maps key names to a republish period overriding RepublishPeriod
+	// for names published with that key, see RepublishIntervalFor. The
+	// republisher does not consume it yet.
+	PerKey map[string]string `json:",omitempty"`
+
 	ResolveCacheSize int
 
 	// MaxCacheTTL is the maximum duration IPNS entries are valid in the cache.
 	MaxCacheTTL *OptionalDuration `json:",omitempty"`
 
+	// MinCacheTTL is the minimum duration IPNS entries are kept in the cache,
+	// regardless of the TTL advertised by the publisher.
+	MinCacheTTL *OptionalDuration `json:",omitempty"`
+
+	// MaxAcceptedLifetime caps the validity of resolved IPNS records: records
+	// expiring further in the future are treated as expired.
+	MaxAcceptedLifetime *OptionalDuration `json:",omitempty"`
+
+	// Resolvers maps IPNS names to trusted delegated routing endpoints
+	// consulted before the regular routing system. The "." key matches every
+	// name without an endpoint of its own.
+	//
+	// Example: `.` → `https://delegated-ipfs.dev`
+	Resolvers map[string]string `json:",omitempty"`
+
 	// Enable namesys pubsub (--enable-namesys-pubsub)
 	UsePubsub Flag `json:",omitempty"`
 }
+
+// Validate checks that RepublishPeriod and RecordLifetime, when set, are
+// valid non-negative durations and that records are not republished less
+// often than they expire, with defaults applied to empty values. PerKey
+// overrides must be valid non-negative durations as well. It also ensures
+// MinCacheTTL exceeds neither MaxCacheTTL nor MaxAcceptedLifetime.
+// Nothing is checked when IPNS is disabled, as the other fields are unused.
+func (i *Ipns) Validate() error {
+	if !i.Enabled.WithDefault(DefaultIpnsEnabled) {
+		return nil
+	}
+
+	repub, err := i.RepublishInterval()
+	if err != nil {
+		return err
+	}
+	lifetime, err := i.Lifetime()
+	if err != nil {
+		return err
+	}
+
+	if repub > lifetime {
+		return fmt.Errorf("config setting IPNS.RepublishPeriod (%s) must not be greater than IPNS.RecordLifetime (%s)", repub, lifetime)
+	}
+
+	for keyName, period := range i.PerKey {
+		if _, err := parseIpnsDuration(fmt.Sprintf("IPNS.PerKey[%q]", keyName), period, repub); err != nil {
+			return err
+		}
+	}
+
+	minTTL := i.MinCacheTTL.WithDefault(DefaultIpnsMinCacheTTL)
+	if minTTL < 0 {
+		return fmt.Errorf("config setting IPNS.MinCacheTTL must not be negative: %s", minTTL)
+	}
+	if maxTTL := i.MaxCacheTTL.WithDefault(DefaultIpnsMaxCacheTTL); minTTL > maxTTL {
+		return fmt.Errorf("config setting IPNS.MinCacheTTL (%s) must not be greater than IPNS.MaxCacheTTL (%s)", minTTL, maxTTL)
+	}
+
+	maxLifetime := i.AcceptedLifetime()
+	if maxLifetime < 0 {
+		return fmt.Errorf("config setting IPNS.MaxAcceptedLifetime must not be negative: %s", maxLifetime)
+	}
+	if minTTL > maxLifetime {
+		return fmt.Errorf("config setting IPNS.MinCacheTTL (%s) must not be greater than IPNS.MaxAcceptedLifetime (%s)", minTTL, maxLifetime)
+	}
+
+	return validateIpnsResolvers(i.Resolvers)
+}
+
+// validateIpnsResolvers checks that every key is "." or an IPNS name, that
+// no two keys are the same name once normalized, and that every resolver is
+// an absolute http(s) URL.
+func validateIpnsResolvers(resolvers map[string]string) error {
+	seen := make(map[string]string, len(resolvers))
+	for key, endpoint := range resolvers {
+		norm := key
+		if key != "." {
+			name, err := ipns.NameFromString(key)
+			if err != nil {
+				return fmt.Errorf("config setting IPNS.Resolvers key %q must be \".\" or an IPNS name: %w", key, err)
+			}
+			norm = name.String()
+		}
+		if other, ok := seen[norm]; ok {
+			return fmt.Errorf("config setting IPNS.Resolvers has duplicate keys %q and %q", other, key)
+		}
+		seen[norm] = key
+
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return fmt.Errorf("config setting IPNS.Resolvers[%q] is not a valid URL: %w", key, err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("config setting IPNS.Resolvers[%q] must be an http or https URL: %q", key, endpoint)
+		}
+	}
+	return nil
+}
+
+// ResolverFor returns the endpoint of the trusted resolver for the given
+// IPNS name: the one configured for that name, in whatever encoding, or the
+// "." fallback.
+func (i *Ipns) ResolverFor(name string) (string, bool) {
+	if n, err := ipns.NameFromString(name); err == nil {
+		for key, endpoint := range i.Resolvers {
+			if k, err := ipns.NameFromString(key); err == nil && k.Equal(n) {
+				return endpoint, true
+			}
+		}
+	}
+	endpoint, ok := i.Resolvers["."]
+	return endpoint, ok
+}
+
+// AcceptedLifetime returns MaxAcceptedLifetime, or
+// DefaultIpnsMaxAcceptedLifetime (no cap) when it is not set.
+func (i *Ipns) AcceptedLifetime() time.Duration {
+	return i.MaxAcceptedLifetime.WithDefault(DefaultIpnsMaxAcceptedLifetime)
+}
+
+// RepublishInterval returns the parsed RepublishPeriod, or
+// DefaultIpnsRepublishPeriod when it is not set.
+func (i *Ipns) RepublishInterval() (time.Duration, error) {
+	return parseIpnsDuration("IPNS.RepublishPeriod", i.RepublishPeriod, DefaultIpnsRepublishPeriod)
+}
+
+// RepublishIntervalFor returns the republish period for the given key name,
+// falling back to RepublishInterval when the key has no PerKey override.
+func (i *Ipns) RepublishIntervalFor(keyName string) (time.Duration, error) {
+	if period, ok := i.PerKey[keyName]; ok && period != "" {
+		return parseIpnsDuration(fmt.Sprintf("IPNS.PerKey[%q]", keyName), period, DefaultIpnsRepublishPeriod)
+	}
+	return i.RepublishInterval()
+}
+
+// Lifetime returns the parsed RecordLifetime, or DefaultIpnsRecordLifetime
+// when it is not set.
+func (i *Ipns) Lifetime() (time.Duration, error) {
+	return parseIpnsDuration("IPNS.RecordLifetime", i.RecordLifetime, DefaultIpnsRecordLifetime)
+}
+
+func parseIpnsDuration(field, value string, defaultValue time.Duration) (time.Duration, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("failure to parse config setting %s: %w", field, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("config setting %s must not be negative: %s", field, d)
+	}
+	return d, nil
+}
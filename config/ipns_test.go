@@ -0,0 +1,168 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIpnsValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		ipns     Ipns
+		hasError bool
+	}{
+		{"empty", Ipns{}, false},
+		{"valid", Ipns{RepublishPeriod: "4h", RecordLifetime: "48h"}, false},
+		{"only republish", Ipns{RepublishPeriod: "4h"}, false},
+		{"only lifetime", Ipns{RecordLifetime: "72h"}, false},
+		{"lifetime below default republish", Ipns{RecordLifetime: "1h"}, true},
+		{"invalid republish", Ipns{RepublishPeriod: "24hh"}, true},
+		{"invalid lifetime", Ipns{RecordLifetime: "forever"}, true},
+		{"negative republish", Ipns{RepublishPeriod: "-1h"}, true},
+		{"negative lifetime", Ipns{RecordLifetime: "-1h"}, true},
+		{"republish greater than lifetime", Ipns{RepublishPeriod: "48h", RecordLifetime: "24h"}, true},
+		{"valid per key override", Ipns{PerKey: map[string]string{"site": "1h"}}, false},
+		{"invalid per key override", Ipns{PerKey: map[string]string{"site": "1hh"}}, true},
+		{"negative per key override", Ipns{PerKey: map[string]string{"site": "-1h"}}, true},
+		{"min cache ttl only", Ipns{MinCacheTTL: NewOptionalDuration(time.Minute)}, false},
+		{"min cache ttl below max", Ipns{MinCacheTTL: NewOptionalDuration(time.Minute), MaxCacheTTL: NewOptionalDuration(time.Hour)}, false},
+		{"min cache ttl above max", Ipns{MinCacheTTL: NewOptionalDuration(time.Hour), MaxCacheTTL: NewOptionalDuration(time.Minute)}, true},
+		{"negative min cache ttl", Ipns{MinCacheTTL: NewOptionalDuration(-time.Minute)}, true},
+		{"max accepted lifetime above record lifetime", Ipns{MaxAcceptedLifetime: NewOptionalDuration(72 * time.Hour)}, false},
+		{"max accepted lifetime below record lifetime", Ipns{RecordLifetime: "48h", MaxAcceptedLifetime: NewOptionalDuration(24 * time.Hour)}, false},
+		{"max accepted lifetime below min cache ttl", Ipns{MinCacheTTL: NewOptionalDuration(2 * time.Hour), MaxAcceptedLifetime: NewOptionalDuration(time.Hour)}, true},
+		{"negative max accepted lifetime", Ipns{MaxAcceptedLifetime: NewOptionalDuration(-time.Hour)}, true},
+		{"valid resolvers", Ipns{Resolvers: map[string]string{".": "https://delegated-ipfs.dev", testIpnsPeerID: "http://127.0.0.1:8080"}}, false},
+		{"resolver with bad scheme", Ipns{Resolvers: map[string]string{".": "ftp://example.com"}}, true},
+		{"resolver without host", Ipns{Resolvers: map[string]string{".": "https://"}}, true},
+		{"unparsable resolver", Ipns{Resolvers: map[string]string{".": "https://exa mple.com/%zz"}}, true},
+		{"empty resolver key", Ipns{Resolvers: map[string]string{"": "https://example.com"}}, true},
+		{"resolver key is not a name", Ipns{Resolvers: map[string]string{"xyz": "https://example.com"}}, true},
+		{"duplicate resolver keys", Ipns{Resolvers: map[string]string{testIpnsPeerID: "https://a.example.com", testIpnsName: "https://b.example.com"}}, true},
+		{"disabled ignores invalid fields", Ipns{Enabled: False, RepublishPeriod: "24hh"}, false},
+		{"explicitly enabled", Ipns{Enabled: True, RepublishPeriod: "24hh"}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.ipns.Validate()
+			if tc.hasError && err == nil {
+				t.Fatal("expected an error")
+			}
+			if !tc.hasError && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestIpnsDurations(t *testing.T) {
+	t.Run("empty uses defaults", func(t *testing.T) {
+		var i Ipns
+		repub, err := i.RepublishInterval()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if repub != DefaultIpnsRepublishPeriod {
+			t.Fatalf("expected %s, got %s", DefaultIpnsRepublishPeriod, repub)
+		}
+		lifetime, err := i.Lifetime()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lifetime != DefaultIpnsRecordLifetime {
+			t.Fatalf("expected %s, got %s", DefaultIpnsRecordLifetime, lifetime)
+		}
+	})
+
+	t.Run("valid values are parsed", func(t *testing.T) {
+		i := Ipns{RepublishPeriod: "90m", RecordLifetime: "72h"}
+		repub, err := i.RepublishInterval()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if repub != 90*time.Minute {
+			t.Fatalf("expected 90m, got %s", repub)
+		}
+		lifetime, err := i.Lifetime()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lifetime != 72*time.Hour {
+			t.Fatalf("expected 72h, got %s", lifetime)
+		}
+	})
+
+	t.Run("invalid values return an error", func(t *testing.T) {
+		i := Ipns{RepublishPeriod: "24hh", RecordLifetime: "1d"}
+		if _, err := i.RepublishInterval(); err == nil {
+			t.Fatal("expected an error for RepublishPeriod")
+		}
+		if _, err := i.Lifetime(); err == nil {
+			t.Fatal("expected an error for RecordLifetime")
+		}
+	})
+}
+
+func TestIpnsRepublishIntervalFor(t *testing.T) {
+	i := Ipns{
+		RepublishPeriod: "2h",
+		PerKey: map[string]string{
+			"fast":   "10m",
+			"broken": "10mm",
+		},
+	}
+
+	d, err := i.RepublishIntervalFor("fast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != 10*time.Minute {
+		t.Fatalf("expected 10m, got %s", d)
+	}
+
+	d, err = i.RepublishIntervalFor("self")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != 2*time.Hour {
+		t.Fatalf("expected fallback to 2h, got %s", d)
+	}
+
+	if _, err = i.RepublishIntervalFor("broken"); err == nil {
+		t.Fatal("expected an error for an invalid override")
+	}
+}
+
+// testIpnsPeerID and testIpnsName are the same key, as a peer ID and as a
+// canonical IPNS name.
+const (
+	testIpnsPeerID = "12D3KooWCup4ShKwKzVJbNsyB4ABK88yzcmPc6kGA5vec66KbvW1"
+	testIpnsName   = "k51qzi5uqu5dhbu3nzgtlu4ni8r1hsgljtbr6p40zc8lf29j4gmefdhdi1bck6"
+	otherIpnsName  = "k51qzi5uqu5dhmdhyz68i5ur8bhalifhmvzdwaqf8zrkk572zj6s8du2gdg77n"
+)
+
+func TestIpnsResolverFor(t *testing.T) {
+	i := Ipns{Resolvers: map[string]string{
+		".":            "https://default.example.com",
+		testIpnsPeerID: "https://key.example.com",
+	}}
+	for name, expected := range map[string]string{
+		testIpnsName:            "https://key.example.com",
+		testIpnsPeerID:          "https://key.example.com",
+		"/ipns/" + testIpnsName: "https://key.example.com",
+		otherIpnsName:           "https://default.example.com",
+	} {
+		endpoint, ok := i.ResolverFor(name)
+		if !ok || endpoint != expected {
+			t.Fatalf("%s: expected %s, got %q (found: %t)", name, expected, endpoint, ok)
+		}
+	}
+
+	if _, ok := (&Ipns{Resolvers: map[string]string{testIpnsPeerID: "https://key.example.com"}}).ResolverFor(otherIpnsName); ok {
+		t.Fatal("expected no resolver without a fallback")
+	}
+
+	empty := Ipns{}
+	if _, ok := empty.ResolverFor("k51abc"); ok {
+		t.Fatal("expected no resolver")
+	}
+}
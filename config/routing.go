@@ -36,6 +36,37 @@ type Router struct {
 	// Parameters are extra configuration that this router might need.
 	// A common one for HTTP router is "Endpoint".
 	Parameters interface{}
+
+	// AllowedMethods, when not empty, lists the only Routing.Methods this
+	// router may be used for, directly or through a composable router.
+	AllowedMethods []MethodName `json:",omitempty"`
+
+	// DeniedMethods lists Routing.Methods this router must never be used for,
+	// directly or through a composable router.
+	DeniedMethods []MethodName `json:",omitempty"`
+
+	// CheckOnStartup makes the daemon probe the router endpoint when it is
+	// created and fail to start if it cannot be reached. Only used by HTTP
+	// routers.
+	CheckOnStartup bool `json:",omitempty"`
+}
+
+// AllowsMethod reports whether the router may be used for the given method.
+func (r *Router) AllowsMethod(mn MethodName) bool {
+	for _, denied := range r.DeniedMethods {
+		if denied == mn {
+			return false
+		}
+	}
+	if len(r.AllowedMethods) == 0 {
+		return true
+	}
+	for _, allowed := range r.AllowedMethods {
+		if allowed == mn {
+			return true
+		}
+	}
+	return false
 }
 
 type (
@@ -69,6 +100,12 @@ func (m Methods) Check() error {
 		return fmt.Errorf("method name %q is not a supported method on Routing.Methods config param", k)
 	}
 
+	for k, v := range m {
+		if v.Timeout.WithDefault(0) < 0 {
+			return fmt.Errorf("timeout of method %q on Routing.Methods config param must not be negative", k)
+		}
+	}
+
 	return nil
 }
 
@@ -100,8 +137,8 @@ func (r *RouterParser) UnmarshalJSON(b []byte) error {
 		return err
 	}
 
-	r.Router.Type = out.Type
-	r.Router.Parameters = p
+	out.Parameters = p
+	r.Router = out
 
 	return nil
 }
@@ -179,4 +216,7 @@ type ConfigRouter struct {
 
 type Method struct {
 	RouterName string
+
+	// Timeout bounds every call made to the router for this method.
+	Timeout *OptionalDuration `json:",omitempty"`
 }
@@ -68,5 +68,9 @@ func Load(filename string) (*config.Config, error) {
 		return nil, err
 	}
 
+	if err := cfg.Ipns.Validate(); err != nil {
+		return nil, err
+	}
+
 	return &cfg, err
 }
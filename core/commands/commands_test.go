@@ -94,6 +94,8 @@ func TestCommands(t *testing.T) {
 		"/filestore",
 		"/filestore/dups",
 		"/filestore/ls",
+		"/filestore/migrate-backing",
+		"/filestore/refcount",
 		"/filestore/verify",
 		"/get",
 		"/id",
@@ -2,11 +2,23 @@ package commands
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
 
+	humanize "github.com/dustin/go-humanize"
+	"github.com/facebookgo/atomicfile"
 	filestore "github.com/ipfs/boxo/filestore"
+	"github.com/ipfs/boxo/filestore/posinfo"
+	dag "github.com/ipfs/boxo/ipld/merkledag"
+	blocks "github.com/ipfs/go-block-format"
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	core "github.com/ipfs/kubo/core"
 	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
@@ -23,13 +35,30 @@ var FileStoreCmd = &cmds.Command{
 		"ls":     lsFileStore,
 		"verify": verifyFileStore,
 		"dups":   dupsFileStore,
+
+		"migrate-backing": migrateBackingFileStore,
+		"refcount":        refcountFileStore,
 	},
 }
 
 const (
-	fileOrderOptionName = "file-order"
+	fileOrderOptionName     = "file-order"
+	repairOptionName        = "repair"
+	repairWhatOptionName    = "what"
+	checkpointOptionName    = "checkpoint"
+	resumeOptionName        = "resume"
+	sortOptionName          = "sort"
+	minSizeOptionName       = "min-size"
+	maxSizeOptionName       = "max-size"
+	dryRunOptionName        = "dry-run"
+	missingBlocksOptionName = "missing-blocks"
+	minRefsOptionName       = "min-refs"
 )
 
+// verifyCheckpointInterval is the number of entries verified between two
+// writes of the 'filestore verify --checkpoint' file. Tests lower it.
+var verifyCheckpointInterval = 1000
+
 var lsFileStore = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "List objects in filestore.",
@@ -42,6 +71,26 @@ otherwise list all objects.
 The output is:
 
 <hash> <size> <path> <offset>
+
+By default objects are streamed in the order they are stored in, which is
+not stable across runs. --sort=hash, --sort=path (then offset) or
+--sort=size orders the listing, but requires holding all of it in memory
+before anything is printed. --file-order also orders by backing file, with
+less memory, but is not guaranteed to be stable for objects of the same file.
+--sort is ignored when objects are given as arguments.
+
+--min-size and --max-size only list objects whose <size> is within the
+given bounds, both inclusive. They accept human sizes such as 100MB or
+4KiB. <size> is the size of the data block stored at <offset> in the
+backing file, as reported by 'ipfs filestore verify', not the size of the
+whole file. Entries that could not be listed are always shown.
+
+--missing-blocks only lists objects whose backing file does not exist. It
+only checks that the file is there, without reading it; use 'ipfs
+filestore verify' to also check its contents. Like the rest of the listing,
+this is per block: a missing file of several blocks is listed once per
+block, and the root CID of the file, which is not kept in the filestore, is
+not shown. With --quiet only the hashes are printed.
 `,
 	},
 	Arguments: []cmds.Argument{
@@ -49,33 +98,85 @@ The output is:
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption(fileOrderOptionName, "sort the results based on the path of the backing file"),
+		cmds.StringOption(sortOptionName, "sort the results by hash, path or size"),
+		cmds.StringOption(minSizeOptionName, "only list objects of at least this size"),
+		cmds.StringOption(maxSizeOptionName, "only list objects of at most this size"),
+		cmds.BoolOption(missingBlocksOptionName, "only list blocks whose backing file is missing"),
+		cmds.BoolOption(quietOptionName, "q", "only print the hashes"),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		_, fs, err := getFilestore(env)
 		if err != nil {
 			return err
 		}
+		keep, err := parseSizeRange(req)
+		if err != nil {
+			return err
+		}
+		if missingBlocks, _ := req.Options[missingBlocksOptionName].(bool); missingBlocks {
+			root, err := filestoreRoot(env)
+			if err != nil {
+				return err
+			}
+			inRange, missing := keep, newMissingFilter(root)
+			keep = func(r *filestore.ListRes) bool {
+				return inRange(r) && missing(r)
+			}
+		}
+
 		args := req.Arguments
 		if len(args) > 0 {
-			return listByArgs(req.Context, res, fs, args)
+			return listByArgs(req.Context, res, fs, args, keep)
 		}
 
 		fileOrder, _ := req.Options[fileOrderOptionName].(bool)
+		sortBy, _ := req.Options[sortOptionName].(string)
+		if sortBy != "" && fileOrder {
+			return fmt.Errorf("--%s and --%s can't be combined", sortOptionName, fileOrderOptionName)
+		}
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+		less, err := listResLess(sortBy, enc.Encode)
+		if err != nil {
+			return err
+		}
+
 		next, err := filestore.ListAll(req.Context, fs, fileOrder)
 		if err != nil {
 			return err
 		}
 
+		var sorted []*filestore.ListRes
 		for {
 			r := next(req.Context)
 			if r == nil {
 				break
 			}
+			if !keep(r) {
+				continue
+			}
+			if less != nil {
+				sorted = append(sorted, r)
+				continue
+			}
 			if err := res.Emit(r); err != nil {
 				return err
 			}
 		}
 
+		if less != nil {
+			sort.SliceStable(sorted, func(i, j int) bool {
+				return less(sorted[i], sorted[j])
+			})
+			for _, r := range sorted {
+				if err := res.Emit(r); err != nil {
+					return err
+				}
+			}
+		}
+
 		return nil
 	},
 	PostRun: cmds.PostRunMap{
@@ -84,11 +185,16 @@ The output is:
 			if err != nil {
 				return err
 			}
+			quiet, _ := res.Request().Options[quietOptionName].(bool)
 			return streamResult(func(v interface{}, out io.Writer) nonFatalError {
 				r := v.(*filestore.ListRes)
 				if r.ErrorMsg != "" {
 					return nonFatalError(r.ErrorMsg)
 				}
+				if quiet {
+					fmt.Fprintf(out, "%s\n", enc.Encode(r.Key))
+					return ""
+				}
 				fmt.Fprintf(out, "%s\n", r.FormatLong(enc.Encode))
 				return ""
 			})(res, re)
@@ -97,6 +203,107 @@ The output is:
 	Type: filestore.ListRes{},
 }
 
+// listResLess returns the ordering of 'filestore ls --sort=<sortBy>', nil
+// when the listing should not be sorted. Hashes are compared in their
+// encoded form so that the output looks sorted.
+func listResLess(sortBy string, encode func(cid.Cid) string) (func(a, b *filestore.ListRes) bool, error) {
+	hash := func(r *filestore.ListRes) string {
+		if !r.Key.Defined() {
+			return ""
+		}
+		return encode(r.Key)
+	}
+
+	switch sortBy {
+	case "":
+		return nil, nil
+	case "hash":
+		return func(a, b *filestore.ListRes) bool {
+			return hash(a) < hash(b)
+		}, nil
+	case "path":
+		return func(a, b *filestore.ListRes) bool {
+			if a.FilePath != b.FilePath {
+				return a.FilePath < b.FilePath
+			}
+			if a.Offset != b.Offset {
+				return a.Offset < b.Offset
+			}
+			return hash(a) < hash(b)
+		}, nil
+	case "size":
+		return func(a, b *filestore.ListRes) bool {
+			if a.Size != b.Size {
+				return a.Size < b.Size
+			}
+			return hash(a) < hash(b)
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid --%s value %q, must be one of: hash, path, size", sortOptionName, sortBy)
+	}
+}
+
+// parseSizeRange returns the --min-size/--max-size filter of
+// 'filestore ls'. Entries carrying an error are never filtered out.
+func parseSizeRange(req *cmds.Request) (func(*filestore.ListRes) bool, error) {
+	minSize, maxSize := uint64(0), uint64(math.MaxUint64)
+	if s, ok := req.Options[minSizeOptionName].(string); ok {
+		n, err := humanize.ParseBytes(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --%s: %w", minSizeOptionName, err)
+		}
+		minSize = n
+	}
+	if s, ok := req.Options[maxSizeOptionName].(string); ok {
+		n, err := humanize.ParseBytes(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --%s: %w", maxSizeOptionName, err)
+		}
+		maxSize = n
+	}
+	if minSize > maxSize {
+		return nil, fmt.Errorf("--%s is larger than --%s", minSizeOptionName, maxSizeOptionName)
+	}
+
+	return func(r *filestore.ListRes) bool {
+		if r.ErrorMsg != "" {
+			return true
+		}
+		return r.Size >= minSize && r.Size <= maxSize
+	}, nil
+}
+
+// newMissingFilter returns the 'filestore ls --missing-blocks' filter. Each
+// backing file is only looked up once. References to URLs are never
+// reported as missing.
+func newMissingFilter(root string) func(*filestore.ListRes) bool {
+	missing := make(map[string]bool)
+	return func(r *filestore.ListRes) bool {
+		if r.ErrorMsg != "" {
+			return true
+		}
+		if filestore.IsURL(r.FilePath) {
+			return false
+		}
+		m, ok := missing[r.FilePath]
+		if !ok {
+			_, err := os.Stat(filepath.Join(root, filepath.FromSlash(r.FilePath)))
+			m = os.IsNotExist(err)
+			missing[r.FilePath] = m
+		}
+		return m
+	}
+}
+
+// verifyResult is the output of 'filestore verify'. After all results, a
+// final verifyResult with only Summary set counts the results per status.
+type verifyResult struct {
+	*filestore.ListRes
+	Repaired    bool           `json:",omitempty"`
+	RepairError string         `json:",omitempty"`
+	Summary     map[string]int `json:",omitempty"`
+}
+
 var verifyFileStore = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Verify objects in filestore.",
@@ -104,7 +311,10 @@ var verifyFileStore = &cmds.Command{
 Verify objects in the filestore.
 
 If one or more <obj> is specified only verify those specific objects,
-otherwise verify all objects.
+otherwise verify all objects. Instead of hashes, <obj> can be absolute
+paths of backing files or directories, in which case every object backed
+by a file at or below one of those paths is verified. Paths and hashes
+can't be mixed.
 
 The output is:
 
@@ -119,41 +329,183 @@ missing:  <obj> could not be found in the filestore
 ERROR:    internal error, most likely due to a corrupt database
 
 For ERROR entries the error will also be printed to stderr.
+
+With --enc=json each result is printed as a JSON object on its own line,
+followed by a final object whose 'Summary' field counts the results per
+status.
+
+With --repair, filestore references whose status is listed in --what
+(by default 'changed' and 'no-file') are removed as soon as they are
+found, and the output line is suffixed with 'removed'. This only drops
+the reference from the filestore; the backing file is never touched.
+
+With --checkpoint=<name>, the last verified key is saved every 1000 entries
+to 'filestore-verify-<name>.json' in the repo directory, and the file is
+removed once verification completes. <name> may only contain letters,
+digits, '-' and '_'. A later run with --checkpoint=<name> --resume skips
+everything up to and including that key, the same --file-order setting must
+be used for both runs. If there is no checkpoint with that name, --resume
+starts from the beginning. --checkpoint can't be combined with <obj>
+arguments.
 `,
 	},
 	Arguments: []cmds.Argument{
-		cmds.StringArg("obj", false, true, "Cid of objects to verify."),
+		cmds.StringArg("obj", false, true, "Cid of objects to verify, or absolute paths of backing files or directories."),
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption(fileOrderOptionName, "verify the objects based on the order of the backing file"),
+		cmds.BoolOption(repairOptionName, "remove references that fail verification"),
+		cmds.StringsOption(repairWhatOptionName, "statuses to remove with --repair: changed, no-file, error").WithDefault([]string{"changed", "no-file"}),
+		cmds.StringOption(checkpointOptionName, "periodically record progress under this name in the repo"),
+		cmds.BoolOption(resumeOptionName, "resume from the key recorded in the --checkpoint"),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		_, fs, err := getFilestore(env)
 		if err != nil {
 			return err
 		}
-		args := req.Arguments
-		if len(args) > 0 {
-			return listByArgs(req.Context, res, fs, args)
+
+		repair, _ := req.Options[repairOptionName].(bool)
+		var repairStatuses map[filestore.Status]bool
+		if repair {
+			what, _ := req.Options[repairWhatOptionName].([]string)
+			repairStatuses, err = parseRepairStatuses(what)
+			if err != nil {
+				return err
+			}
+		}
+
+		summary := make(map[string]int)
+		emitSummary := func() error {
+			return res.Emit(&verifyResult{Summary: summary})
+		}
+		emit := func(r *filestore.ListRes) (*verifyResult, error) {
+			summary[strings.TrimSpace(r.Status.Format())]++
+			out := &verifyResult{ListRes: r}
+			if repairStatuses[r.Status] {
+				if err := fs.FileManager().DeleteBlock(req.Context, r.Key); err != nil {
+					out.RepairError = err.Error()
+				} else {
+					out.Repaired = true
+				}
+			}
+			return out, res.Emit(out)
 		}
 
 		fileOrder, _ := req.Options[fileOrderOptionName].(bool)
-		next, err := filestore.VerifyAll(req.Context, fs, fileOrder)
+		checkpointName, _ := req.Options[checkpointOptionName].(string)
+		resume, _ := req.Options[resumeOptionName].(bool)
+		if resume && checkpointName == "" {
+			return fmt.Errorf("--%s requires --%s", resumeOptionName, checkpointOptionName)
+		}
+
+		args := req.Arguments
+		if len(args) > 0 && checkpointName != "" {
+			return fmt.Errorf("--%s can only be used when verifying the whole filestore, not with <obj> arguments", checkpointOptionName)
+		}
+		var checkpointPath string
+		if checkpointName != "" {
+			cfgRoot, err := cmdenv.GetConfigRoot(env)
+			if err != nil {
+				return err
+			}
+			checkpointPath, err = verifyCheckpointPath(cfgRoot, checkpointName)
+			if err != nil {
+				return err
+			}
+		}
+		prefixes, err := verifyPathArgs(env, args)
 		if err != nil {
 			return err
 		}
+		if prefixes != nil {
+			next, err := filestore.ListAll(req.Context, fs, fileOrder)
+			if err != nil {
+				return err
+			}
+			for {
+				r := next(req.Context)
+				if r == nil {
+					break
+				}
+				if r.Status != filestore.StatusOk {
+					if _, err := emit(r); err != nil {
+						return err
+					}
+					continue
+				}
+				for _, prefix := range prefixes {
+					if _, ok := trimPathPrefix(r.FilePath, prefix); ok {
+						if _, err := emit(filestore.Verify(req.Context, fs, r.Key)); err != nil {
+							return err
+						}
+						break
+					}
+				}
+			}
+			if err := req.Context.Err(); err != nil {
+				return err
+			}
+			return emitSummary()
+		}
+		if len(args) > 0 {
+			for _, arg := range args {
+				c, err := cid.Decode(arg)
+				if err != nil {
+					ret := &filestore.ListRes{
+						Status:   filestore.StatusOtherError,
+						ErrorMsg: fmt.Sprintf("%s: %v", arg, err),
+					}
+					if _, err := emit(ret); err != nil {
+						return err
+					}
+					continue
+				}
+				if _, err := emit(filestore.Verify(req.Context, fs, c)); err != nil {
+					return err
+				}
+			}
+			return emitSummary()
+		}
 
-		for {
-			r := next(req.Context)
-			if r == nil {
-				break
+		var checkpoint *verifyCheckpoint
+		if resume {
+			checkpoint, err = readVerifyCheckpoint(checkpointPath)
+			if err != nil {
+				return err
 			}
-			if err := res.Emit(r); err != nil {
+			if checkpoint != nil && checkpoint.FileOrder != fileOrder {
+				return fmt.Errorf("checkpoint %q was written with --%s=%t, rerun with the same ordering", checkpointName, fileOrderOptionName, checkpoint.FileOrder)
+			}
+		}
+
+		var next func(context.Context) *filestore.ListRes
+		found := func() bool { return true }
+		if checkpoint != nil {
+			list, err := filestore.ListAll(req.Context, fs, fileOrder)
+			if err != nil {
+				return err
+			}
+			next, found = resumeVerify(list, func(ctx context.Context, c cid.Cid) *filestore.ListRes {
+				return filestore.Verify(ctx, fs, c)
+			}, checkpoint.Key)
+		} else {
+			next, err = filestore.VerifyAll(req.Context, fs, fileOrder)
+			if err != nil {
 				return err
 			}
 		}
 
-		return nil
+		err = verifyCheckpointed(req.Context, next, emit, checkpointPath, fileOrder, func() error {
+			if !found() {
+				return fmt.Errorf("key %s from checkpoint %q is no longer in the filestore, rerun without --%s to start over", checkpoint.Key, checkpointName, resumeOptionName)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return emitSummary()
 	},
 	PostRun: cmds.PostRunMap{
 		cmds.CLI: func(res cmds.Response, re cmds.ResponseEmitter) error {
@@ -162,6 +514,12 @@ For ERROR entries the error will also be printed to stderr.
 				return err
 			}
 
+			switch cmds.GetEncoding(res.Request(), cmds.Text) {
+			case cmds.Text, cmds.CLI:
+			default:
+				return cmds.Copy(re, res)
+			}
+
 			for {
 				v, err := res.Next()
 				if err != nil {
@@ -171,19 +529,296 @@ For ERROR entries the error will also be printed to stderr.
 					return err
 				}
 
-				list, ok := v.(*filestore.ListRes)
+				list, ok := v.(*verifyResult)
 				if !ok {
 					return e.TypeErr(list, v)
 				}
+				// The summary is only part of structured output.
+				if list.Summary != nil {
+					continue
+				}
 
 				if list.Status == filestore.StatusOtherError {
 					fmt.Fprintf(os.Stderr, "%s\n", list.ErrorMsg)
 				}
-				fmt.Fprintf(os.Stdout, "%s %s\n", list.Status.Format(), list.FormatLong(enc.Encode))
+				if list.RepairError != "" {
+					fmt.Fprintf(os.Stderr, "failed to remove %s: %s\n", list.Key, list.RepairError)
+				}
+				fmt.Fprint(os.Stdout, list.format(enc.Encode))
 			}
 		},
 	},
-	Type: filestore.ListRes{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *verifyResult) error {
+			if out.Summary != nil {
+				return nil
+			}
+			enc, err := cmdenv.GetCidEncoder(req)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprint(w, out.format(enc.Encode))
+			return err
+		}),
+	},
+	Type: verifyResult{},
+}
+
+// format returns the text output line for r.
+func (r *verifyResult) format(encode func(cid.Cid) string) string {
+	if r.Repaired {
+		return fmt.Sprintf("%s %s removed\n", r.Status.Format(), r.FormatLong(encode))
+	}
+	return fmt.Sprintf("%s %s\n", r.Status.Format(), r.FormatLong(encode))
+}
+
+// verifyPathArgs returns the filestore relative form of args when they are
+// all absolute paths, or nil when none of them is.
+func verifyPathArgs(env cmds.Environment, args []string) ([]string, error) {
+	var paths int
+	for _, arg := range args {
+		if filepath.IsAbs(arg) {
+			paths++
+		}
+	}
+	if paths == 0 {
+		return nil, nil
+	}
+	if paths != len(args) {
+		return nil, errors.New("paths and hashes can't be mixed")
+	}
+
+	root, err := filestoreRoot(env)
+	if err != nil {
+		return nil, err
+	}
+	prefixes := make([]string, len(args))
+	for i, arg := range args {
+		prefixes[i], err = filestoreRelPath(root, arg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return prefixes, nil
+}
+
+// resumeVerify walks list without reading backing files until the entry with
+// the given key is reached, then returns the verification of the remaining
+// entries. found reports whether the key was seen.
+func resumeVerify(list func(context.Context) *filestore.ListRes, verify func(context.Context, cid.Cid) *filestore.ListRes, key string) (next func(context.Context) *filestore.ListRes, found func() bool) {
+	skipping := true
+	next = func(ctx context.Context) *filestore.ListRes {
+		for {
+			r := list(ctx)
+			if r == nil {
+				return nil
+			}
+			if skipping {
+				if r.Key.Defined() && r.Key.String() == key {
+					skipping = false
+				}
+				continue
+			}
+			if r.Status != filestore.StatusOk {
+				return r
+			}
+			return verify(ctx, r.Key)
+		}
+	}
+	return next, func() bool { return !skipping }
+}
+
+// verifyCheckpointed emits every result of next. When checkpointPath is set,
+// the last verified key is saved to it every verifyCheckpointInterval entries,
+// and it is removed once next is exhausted if done, which checks that the run
+// is complete, succeeds.
+func verifyCheckpointed(ctx context.Context, next func(context.Context) *filestore.ListRes, emit func(*filestore.ListRes) (*verifyResult, error), checkpointPath string, fileOrder bool, done func() error) error {
+	var count int
+	var lastKey cid.Cid
+	for {
+		r := next(ctx)
+		if r == nil {
+			break
+		}
+		out, err := emit(r)
+		if err != nil {
+			return err
+		}
+		// Never checkpoint a removed reference, it would not be found
+		// when resuming.
+		if r.Key.Defined() && !out.Repaired {
+			lastKey = r.Key
+		}
+		count++
+		if checkpointPath != "" && count%verifyCheckpointInterval == 0 && lastKey.Defined() {
+			if err := writeVerifyCheckpoint(checkpointPath, &verifyCheckpoint{Key: lastKey.String(), FileOrder: fileOrder}); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := done(); err != nil {
+		return err
+	}
+	if checkpointPath != "" {
+		if err := os.Remove(checkpointPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyCheckpointPath returns the file of the 'filestore verify' checkpoint
+// with the given name. Checkpoints are always kept in the repo root, so the
+// name is restricted to characters that can't form another path.
+func verifyCheckpointPath(repoRoot, name string) (string, error) {
+	valid := name != "" && len(name) <= 64
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			valid = false
+			break
+		}
+	}
+	if !valid {
+		return "", fmt.Errorf("invalid checkpoint name %q: use up to 64 letters, digits, '-' or '_'", name)
+	}
+	return filepath.Join(repoRoot, "filestore-verify-"+name+".json"), nil
+}
+
+// verifyCheckpoint is the progress saved by 'filestore verify --checkpoint'.
+type verifyCheckpoint struct {
+	Key       string
+	FileOrder bool
+}
+
+// readVerifyCheckpoint returns nil, without an error, when no checkpoint has
+// been written yet.
+func readVerifyCheckpoint(path string) (*verifyCheckpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var cp verifyCheckpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint %s: %w", path, err)
+	}
+	return &cp, nil
+}
+
+func writeVerifyCheckpoint(path string, cp *verifyCheckpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	f, err := atomicfile.New(path, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Abort()
+		return err
+	}
+	return f.Close()
+}
+
+// parseRepairStatuses maps the --what values of 'filestore verify --repair'
+// to the filestore statuses that should be removed.
+func parseRepairStatuses(what []string) (map[filestore.Status]bool, error) {
+	statuses := make(map[filestore.Status]bool, len(what))
+	for _, w := range what {
+		switch w {
+		case filestore.StatusFileChanged.String():
+			statuses[filestore.StatusFileChanged] = true
+		case filestore.StatusFileNotFound.String():
+			statuses[filestore.StatusFileNotFound] = true
+		case filestore.StatusFileError.String():
+			statuses[filestore.StatusFileError] = true
+		default:
+			return nil, fmt.Errorf("invalid --%s value %q, must be one of: changed, no-file, error", repairWhatOptionName, w)
+		}
+	}
+	return statuses, nil
+}
+
+type refcountResult struct {
+	FilePath string
+	Refs     int
+}
+
+var refcountFileStore = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Count the filestore objects backed by each file.",
+		LongDescription: `
+Count, for every backing file, how many filestore objects reference it.
+Deleting or changing a file breaks all of them, whatever file or
+directory they were added as part of.
+
+The output is:
+
+<refs> <path>
+
+sorted by path. With --min-refs=<n>, only files referenced by at least n
+objects are listed.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.IntOption(minRefsOptionName, "only list files referenced by at least this many objects").WithDefault(1),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		_, fs, err := getFilestore(env)
+		if err != nil {
+			return err
+		}
+		minRefs, _ := req.Options[minRefsOptionName].(int)
+
+		next, err := filestore.ListAll(req.Context, fs, false)
+		if err != nil {
+			return err
+		}
+
+		refs := make(map[string]int)
+		for {
+			r := next(req.Context)
+			if r == nil {
+				break
+			}
+			if r.ErrorMsg != "" {
+				return errors.New(r.ErrorMsg)
+			}
+			refs[r.FilePath]++
+		}
+		if err := req.Context.Err(); err != nil {
+			return err
+		}
+
+		paths := make([]string, 0, len(refs))
+		for p, n := range refs {
+			if n >= minRefs {
+				paths = append(paths, p)
+			}
+		}
+		sort.Strings(paths)
+
+		for _, p := range paths {
+			if err := res.Emit(&refcountResult{FilePath: p, Refs: refs[p]}); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *refcountResult) error {
+			_, err := fmt.Fprintf(w, "%d %s\n", out.Refs, out.FilePath)
+			return err
+		}),
+	},
+	Type: refcountResult{},
 }
 
 var dupsFileStore = &cmds.Command{
@@ -224,6 +859,202 @@ var dupsFileStore = &cmds.Command{
 	Type:     RefWrapper{},
 }
 
+type migrateBackingResult struct {
+	Key      cid.Cid
+	From     string
+	To       string
+	Offset   uint64
+	Migrated bool   `json:",omitempty"`
+	Error    string `json:",omitempty"`
+}
+
+var migrateBackingFileStore = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Rewrite the backing file paths of filestore objects.",
+		LongDescription: `
+Rewrite the backing file path of every filestore object stored under
+<old-prefix> so that it points under <new-prefix> instead. This is useful
+after the backing files were moved to another location, which otherwise
+requires adding them again.
+
+Prefixes are matched on whole path components. They may be absolute, or
+relative to the filestore root as printed by 'ipfs filestore ls'; both
+must be inside the filestore root. The daemon, when one is running,
+resolves and reads the paths.
+
+Before a reference is rewritten, the data at its new location is read and
+checked against the object hash. Objects whose new location can't be read
+or doesn't match are left untouched and reported on stderr.
+
+The output is:
+
+<hash> <old-path> <new-path> <offset>
+
+With --dry-run the new locations are still checked but nothing is written.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("old-prefix", true, false, "Path prefix the backing files were moved from."),
+		cmds.StringArg("new-prefix", true, false, "Path prefix the backing files were moved to."),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(dryRunOptionName, "only show what would be rewritten"),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		_, fs, err := getFilestore(env)
+		if err != nil {
+			return err
+		}
+		root, err := filestoreRoot(env)
+		if err != nil {
+			return err
+		}
+
+		oldPrefix, err := filestoreRelPath(root, req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		newPrefix, err := filestoreRelPath(root, req.Arguments[1])
+		if err != nil {
+			return err
+		}
+		dryRun, _ := req.Options[dryRunOptionName].(bool)
+
+		next, err := filestore.ListAll(req.Context, fs, true)
+		if err != nil {
+			return err
+		}
+
+		// Collect the matches first so that the listing doesn't observe
+		// its own writes.
+		var matches []*filestore.ListRes
+		for {
+			r := next(req.Context)
+			if r == nil {
+				break
+			}
+			if r.ErrorMsg != "" || filestore.IsURL(r.FilePath) {
+				continue
+			}
+			if _, ok := trimPathPrefix(r.FilePath, oldPrefix); ok {
+				matches = append(matches, r)
+			}
+		}
+		if err := req.Context.Err(); err != nil {
+			return err
+		}
+
+		for _, r := range matches {
+			rest, _ := trimPathPrefix(r.FilePath, oldPrefix)
+			out := &migrateBackingResult{
+				Key:    r.Key,
+				From:   r.FilePath,
+				To:     path.Join(newPrefix, rest),
+				Offset: r.Offset,
+			}
+			fullPath := filepath.Join(root, filepath.FromSlash(out.To))
+
+			blk, err := readBackingBlock(fullPath, r.Key, r.Offset, r.Size)
+			if err == nil && !dryRun {
+				err = fs.FileManager().Put(req.Context, &posinfo.FilestoreNode{
+					PosInfo: &posinfo.PosInfo{Offset: r.Offset, FullPath: fullPath},
+					Node:    &dag.RawNode{Block: blk},
+				})
+				out.Migrated = err == nil
+			}
+			if err != nil {
+				out.Error = err.Error()
+			}
+
+			if err := res.Emit(out); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *migrateBackingResult) error {
+			enc, err := cmdenv.GetCidEncoder(req)
+			if err != nil {
+				return err
+			}
+			if out.Error != "" {
+				fmt.Fprintf(os.Stderr, "cannot migrate %s to %s: %s\n", enc.Encode(out.Key), out.To, out.Error)
+				return nil
+			}
+			_, err = fmt.Fprintf(w, "%s %s %s %d\n", enc.Encode(out.Key), out.From, out.To, out.Offset)
+			return err
+		}),
+	},
+	Type: migrateBackingResult{},
+}
+
+// filestoreRoot returns the directory filestore paths are relative to, the
+// one that contains the repo.
+func filestoreRoot(env cmds.Environment) (string, error) {
+	cfgRoot, err := cmdenv.GetConfigRoot(env)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(filepath.Dir(cfgRoot))
+}
+
+// filestoreRelPath returns p relative to the filestore root, in the slash
+// separated form the filestore stores paths in.
+func filestoreRelPath(root, p string) (string, error) {
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(root, p)
+	}
+	rel, err := filepath.Rel(root, filepath.Clean(p))
+	if err != nil {
+		return "", err
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside of the filestore root (%s)", p, root)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// trimPathPrefix strips prefix from p if prefix is made of leading path
+// components of p.
+func trimPathPrefix(p, prefix string) (string, bool) {
+	if prefix == "." {
+		return p, true
+	}
+	if p == prefix {
+		return "", true
+	}
+	if strings.HasPrefix(p, prefix+"/") {
+		return p[len(prefix)+1:], true
+	}
+	return "", false
+}
+
+// readBackingBlock reads size bytes at offset from the file name and
+// checks that they hash to key.
+func readBackingBlock(name string, key cid.Cid, offset, size uint64) (blocks.Block, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data := make([]byte, size)
+	if _, err := f.ReadAt(data, int64(offset)); err != nil {
+		return nil, err
+	}
+
+	c, err := key.Prefix().Sum(data)
+	if err != nil {
+		return nil, err
+	}
+	if !c.Equals(key) {
+		return nil, fmt.Errorf("data at offset %d does not match", offset)
+	}
+	return blocks.NewBlockWithCid(data, c)
+}
+
 func getFilestore(env cmds.Environment) (*core.IpfsNode, *filestore.Filestore, error) {
 	n, err := cmdenv.GetNode(env)
 	if err != nil {
@@ -236,7 +1067,7 @@ func getFilestore(env cmds.Environment) (*core.IpfsNode, *filestore.Filestore, e
 	return n, fs, err
 }
 
-func listByArgs(ctx context.Context, res cmds.ResponseEmitter, fs *filestore.Filestore, args []string) error {
+func listByArgs(ctx context.Context, res cmds.ResponseEmitter, fs *filestore.Filestore, args []string, inRange func(*filestore.ListRes) bool) error {
 	for _, arg := range args {
 		c, err := cid.Decode(arg)
 		if err != nil {
@@ -250,6 +1081,9 @@ func listByArgs(ctx context.Context, res cmds.ResponseEmitter, fs *filestore.Fil
 			continue
 		}
 		r := filestore.Verify(ctx, fs, c)
+		if !inRange(r) {
+			continue
+		}
 		if err := res.Emit(r); err != nil {
 			return err
 		}
@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/boxo/filestore"
+	"github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyCheckpointResume(t *testing.T) {
+	defer func(interval int) { verifyCheckpointInterval = interval }(verifyCheckpointInterval)
+	verifyCheckpointInterval = 10
+
+	ctx := context.Background()
+	checkpointPath := filepath.Join(t.TempDir(), "checkpoint")
+
+	var entries []*filestore.ListRes
+	for i := 0; i < 25; i++ {
+		h, err := mh.Sum([]byte(fmt.Sprint(i)), mh.SHA2_256, -1)
+		require.NoError(t, err)
+		entries = append(entries, &filestore.ListRes{Status: filestore.StatusOk, Key: cid.NewCidV1(cid.Raw, h)})
+	}
+	list := func() func(context.Context) *filestore.ListRes {
+		i := 0
+		return func(context.Context) *filestore.ListRes {
+			if i == len(entries) {
+				return nil
+			}
+			i++
+			return entries[i-1]
+		}
+	}
+	verify := func(_ context.Context, c cid.Cid) *filestore.ListRes {
+		return &filestore.ListRes{Status: filestore.StatusOk, Key: c}
+	}
+	complete := func() error { return nil }
+
+	// Interrupt the first run after 23 entries, the checkpoint is at 20.
+	errInterrupted := errors.New("interrupted")
+	var verified []cid.Cid
+	interruptAt := 23
+	emit := func(r *filestore.ListRes) (*verifyResult, error) {
+		if len(verified) == interruptAt {
+			return nil, errInterrupted
+		}
+		verified = append(verified, r.Key)
+		return &verifyResult{ListRes: r}, nil
+	}
+	err := verifyCheckpointed(ctx, list(), emit, checkpointPath, false, complete)
+	require.ErrorIs(t, err, errInterrupted)
+
+	checkpoint, err := readVerifyCheckpoint(checkpointPath)
+	require.NoError(t, err)
+	require.Equal(t, entries[19].Key.String(), checkpoint.Key)
+
+	// Resuming verifies everything after the checkpoint exactly once.
+	verified = verified[:20]
+	interruptAt = -1
+	next, found := resumeVerify(list(), verify, checkpoint.Key)
+	err = verifyCheckpointed(ctx, next, emit, checkpointPath, false, complete)
+	require.NoError(t, err)
+	require.True(t, found())
+	require.Len(t, verified, len(entries))
+	for i, entry := range entries {
+		require.Equal(t, entry.Key, verified[i], "entry %d", i)
+	}
+	require.NoFileExists(t, checkpointPath)
+
+	// A checkpoint whose key is gone is reported, and kept.
+	require.NoError(t, writeVerifyCheckpoint(checkpointPath, &verifyCheckpoint{Key: "gone"}))
+	next, found = resumeVerify(list(), verify, "gone")
+	errGone := errors.New("gone")
+	err = verifyCheckpointed(ctx, next, emit, checkpointPath, false, func() error {
+		if !found() {
+			return errGone
+		}
+		return nil
+	})
+	require.ErrorIs(t, err, errGone)
+	require.FileExists(t, checkpointPath)
+}
+
+func TestVerifyCheckpointPath(t *testing.T) {
+	p, err := verifyCheckpointPath("/repo", "nightly-run_2")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join("/repo", "filestore-verify-nightly-run_2.json"), p)
+
+	for _, name := range []string{"", ".", "..", "../config", "/etc/passwd", "a/b", "a\\b", "a.b", strings.Repeat("a", 65)} {
+		_, err := verifyCheckpointPath("/repo", name)
+		require.Error(t, err, name)
+	}
+}
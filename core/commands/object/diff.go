@@ -14,10 +14,34 @@ import (
 
 const (
 	verboseOptionName = "verbose"
+	statOptionName    = "stat"
 )
 
 type Changes struct {
 	Changes []*dagutils.Change
+	Stat    *DiffStat `json:",omitempty"`
+}
+
+// DiffStat counts the changes of a diff by type.
+type DiffStat struct {
+	Added    int
+	Removed  int
+	Modified int
+}
+
+func diffStat(changes []*dagutils.Change) *DiffStat {
+	stat := &DiffStat{}
+	for _, change := range changes {
+		switch change.Type {
+		case dagutils.Add:
+			stat.Added++
+		case dagutils.Remove:
+			stat.Removed++
+		case dagutils.Mod:
+			stat.Modified++
+		}
+	}
+	return stat
 }
 
 var ObjectDiffCmd = &cmds.Command{
@@ -46,6 +70,12 @@ Example:
    > OBJ_B=QmcmRptkSPWhptCttgHg27QNDmnV33wAJyUkCnAvqD3eCD
    > ipfs object diff -v $OBJ_A $OBJ_B
    Changed "bar" from QmNgd5cz2jNftnAHBhcRUGdtiaMzb5Rhjqd4etondHHST8 to QmRfFVsjSXkhFxrfWnLpMae2M4GBVsry6VAuYYcji5MiZb.
+
+With --stat only the number of added, removed and modified links is
+printed instead of the changes themselves:
+
+   > ipfs object diff --stat $OBJ_A $OBJ_B
+   0 added, 0 removed, 1 modified
 `,
 	},
 	Arguments: []cmds.Argument{
@@ -54,6 +84,7 @@ Example:
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption(verboseOptionName, "v", "Print extra information."),
+		cmds.BoolOption(statOptionName, "Only print the number of changes of each type."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		api, err := cmdenv.GetApi(env, req)
@@ -92,11 +123,19 @@ Example:
 			}
 		}
 
-		return cmds.EmitOnce(res, &Changes{out})
+		if stat, _ := req.Options[statOptionName].(bool); stat {
+			return cmds.EmitOnce(res, &Changes{Stat: diffStat(out)})
+		}
+		return cmds.EmitOnce(res, &Changes{Changes: out})
 	},
 	Type: Changes{},
 	Encoders: cmds.EncoderMap{
 		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *Changes) error {
+			if out.Stat != nil {
+				fmt.Fprintf(w, "%d added, %d removed, %d modified\n", out.Stat.Added, out.Stat.Removed, out.Stat.Modified)
+				return nil
+			}
+
 			verbose, _ := req.Options[verboseOptionName].(bool)
 
 			for _, change := range out.Changes {
@@ -20,6 +20,7 @@ import (
 	protocol "github.com/libp2p/go-libp2p/core/protocol"
 	ma "github.com/multiformats/go-multiaddr"
 	madns "github.com/multiformats/go-multiaddr-dns"
+	manet "github.com/multiformats/go-multiaddr/net"
 )
 
 // P2PProtoPrefix is the default required prefix for protocol names
@@ -53,6 +54,11 @@ type P2PStreamsOutput struct {
 const (
 	allowCustomProtocolOptionName = "allow-custom-protocol"
 	reportPeerIDOptionName        = "report-peer-id"
+	idleTimeoutOptionName         = "idle-timeout"
+	udpOptionName                 = "udp"
+	allowOptionName               = "allow"
+	retryOptionName               = "retry"
+	retryDelayOptionName          = "retry-delay"
 )
 
 var resolveTimeout = 10 * time.Second
@@ -92,6 +98,13 @@ Example:
   ipfs p2p forward ` + P2PProtoPrefix + `myproto /ip4/127.0.0.1/tcp/4567 /p2p/QmPeer
     - Forward connections to 127.0.0.1:4567 to '` + P2PProtoPrefix + `myproto' service on /p2p/QmPeer
 
+  ipfs p2p forward --udp ` + P2PProtoPrefix + `dns /ip4/127.0.0.1/udp/5353 /p2p/QmPeer
+    - Forward UDP datagrams sent to 127.0.0.1:5353 to '` + P2PProtoPrefix + `dns' service on /p2p/QmPeer
+
+With --udp, every local UDP peer gets its own stream. At most 256 peers are
+served at once, the least recently active one is dropped to make room for a
+new one.
+
 `,
 	},
 	Arguments: []cmds.Argument{
@@ -101,6 +114,10 @@ Example:
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption(allowCustomProtocolOptionName, "Don't require /x/ prefix"),
+		cmds.BoolOption(udpOptionName, "Forward UDP datagrams instead of TCP connections. The other end must use --udp too."),
+		cmds.StringOption(idleTimeoutOptionName, "Close forwarded connections after no data has been sent in either direction for this long (e.g. 10m), 0 disables it. Disabled by default, except with --udp where it defaults to 1m."),
+		cmds.IntOption(retryOptionName, "Number of times to retry reaching the target when it fails, for every forwarded connection.").WithDefault(0),
+		cmds.StringOption(retryDelayOptionName, "Delay before the first retry, doubled after each one.").WithDefault("1s"),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		n, err := p2pGetNode(env)
@@ -123,6 +140,9 @@ Example:
 		if err != nil {
 			return err
 		}
+		if err := checkDialable(targets); err != nil {
+			return err
+		}
 
 		allowCustom, _ := req.Options[allowCustomProtocolOptionName].(bool)
 
@@ -130,7 +150,23 @@ Example:
 			return errors.New("protocol name must be within '" + P2PProtoPrefix + "' namespace")
 		}
 
-		return forwardLocal(n.Context(), n.P2P, n.Peerstore, proto, listen, targets)
+		opts, err := parseIdleTimeout(req)
+		if err != nil {
+			return err
+		}
+
+		retry, err := parseDialRetry(req)
+		if err != nil {
+			return err
+		}
+
+		udp, _ := req.Options[udpOptionName].(bool)
+		if udp && !p2p.IsUDPAddr(listen) {
+			return errors.New("--udp requires a udp listen-address")
+		}
+
+		opts = append(opts, p2p.WithDialRetry(retry))
+		return forwardLocal(n.Context(), n.P2P, n.Peerstore, proto, listen, targets, udp, opts...)
 	},
 }
 
@@ -138,7 +174,7 @@ Example:
 func parseIpfsAddr(addr string) (*peer.AddrInfo, error) {
 	multiaddr, err := ma.NewMultiaddr(addr)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("invalid peer address %q: %w", addr, err)
 	}
 
 	pi, err := peer.AddrInfoFromP2pAddr(multiaddr)
@@ -177,9 +213,28 @@ func parseIpfsAddr(addr string) (*peer.AddrInfo, error) {
 		}
 		info.Addrs = append(info.Addrs, taddr)
 	}
+	if info.ID == "" {
+		return nil, fmt.Errorf("invalid peer address %q: it does not contain a /p2p/ peer ID", addr)
+	}
 	return &info, nil
 }
 
+// checkDialable returns an error when all the addresses of info are
+// unspecified addresses (0.0.0.0 or ::), which can't be dialed. Loopback
+// addresses are fine, the peer may run on the same host. Without addresses,
+// the peer is looked up in the routing system.
+func checkDialable(info *peer.AddrInfo) error {
+	if len(info.Addrs) == 0 {
+		return nil
+	}
+	for _, addr := range info.Addrs {
+		if !manet.IsIPUnspecified(addr) {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid peer address: %s only has unspecified addresses, which can't be dialed", info.ID)
+}
+
 var p2pListenCmd = &cmds.Command{
 	Status: cmds.Experimental,
 	Helptext: cmds.HelpText{
@@ -193,6 +248,16 @@ Example:
   ipfs p2p listen ` + P2PProtoPrefix + `myproto /ip4/127.0.0.1/tcp/1234
     - Forward connections to 'myproto' libp2p service to 127.0.0.1:1234
 
+  ipfs p2p listen --udp ` + P2PProtoPrefix + `dns /ip4/127.0.0.1/udp/53
+    - Forward datagrams sent to 'dns' libp2p service to 127.0.0.1:53
+
+  ipfs p2p listen --allow QmPeer1,QmPeer2 ` + P2PProtoPrefix + `myproto /ip4/127.0.0.1/tcp/1234
+    - Only forward connections from QmPeer1 and QmPeer2 to 127.0.0.1:1234
+
+--allow checks the peer ID authenticated by the libp2p connection. It limits who
+can reach the service, but it is not a substitute for encryption: traffic
+between this node and <target-address> is forwarded as is.
+
 `,
 	},
 	Arguments: []cmds.Argument{
@@ -202,6 +267,9 @@ Example:
 	Options: []cmds.Option{
 		cmds.BoolOption(allowCustomProtocolOptionName, "Don't require /x/ prefix"),
 		cmds.BoolOption(reportPeerIDOptionName, "r", "Send remote base58 peerid to target when a new connection is established"),
+		cmds.DelimitedStringsOption(",", allowOptionName, "Only accept connections from these peer IDs (comma-separated). Accepts all peers by default."),
+		cmds.BoolOption(udpOptionName, "Forward UDP datagrams instead of TCP connections. The other end must use --udp too."),
+		cmds.StringOption(idleTimeoutOptionName, "Close forwarded connections after no data has been sent in either direction for this long (e.g. 10m), 0 disables it. Disabled by default, except with --udp where it defaults to 1m."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		n, err := p2pGetNode(env)
@@ -231,7 +299,31 @@ Example:
 			return errors.New("protocol name must be within '" + P2PProtoPrefix + "' namespace")
 		}
 
-		_, err = n.P2P.ForwardRemote(n.Context(), proto, target, reportPeerID)
+		opts, err := parseIdleTimeout(req)
+		if err != nil {
+			return err
+		}
+
+		allowOpt, _ := req.Options[allowOptionName].([]string)
+		allowed := make([]peer.ID, 0, len(allowOpt))
+		for _, s := range allowOpt {
+			p, err := peer.Decode(s)
+			if err != nil {
+				return fmt.Errorf("invalid peer ID in --%s: %q: %w", allowOptionName, s, err)
+			}
+			allowed = append(allowed, p)
+		}
+
+		opts = append(opts, p2p.WithAllowedPeers(allowed...))
+		if udp, _ := req.Options[udpOptionName].(bool); udp {
+			if !p2p.IsUDPAddr(target) {
+				return errors.New("--udp requires a udp target-address")
+			}
+			_, err = n.P2P.ForwardRemoteUDP(n.Context(), proto, target, reportPeerID, opts...)
+			return err
+		}
+
+		_, err = n.P2P.ForwardRemote(n.Context(), proto, target, reportPeerID, opts...)
 		return err
 	},
 }
@@ -270,11 +362,51 @@ func checkPort(target ma.Multiaddr) error {
 	return nil
 }
 
+// parseIdleTimeout returns the forward option for the --idle-timeout option,
+// none when it is not set so that the default of the listener applies.
+func parseIdleTimeout(req *cmds.Request) ([]p2p.ForwardOption, error) {
+	idleTimeoutOpt, ok := req.Options[idleTimeoutOptionName].(string)
+	if !ok {
+		return nil, nil
+	}
+	idleTimeout, err := time.ParseDuration(idleTimeoutOpt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", idleTimeoutOptionName, err)
+	}
+	if idleTimeout < 0 {
+		return nil, fmt.Errorf("%s must not be negative", idleTimeoutOptionName)
+	}
+	return []p2p.ForwardOption{p2p.WithIdleTimeout(idleTimeout)}, nil
+}
+
+// parseDialRetry returns the --retry and --retry-delay options of 'p2p
+// forward'.
+func parseDialRetry(req *cmds.Request) (p2p.DialRetry, error) {
+	retries, _ := req.Options[retryOptionName].(int)
+	if retries < 0 {
+		return p2p.DialRetry{}, fmt.Errorf("%s must not be negative", retryOptionName)
+	}
+	delayOpt, _ := req.Options[retryDelayOptionName].(string)
+	delay, err := time.ParseDuration(delayOpt)
+	if err != nil {
+		return p2p.DialRetry{}, fmt.Errorf("invalid %s: %w", retryDelayOptionName, err)
+	}
+	if delay < 0 {
+		return p2p.DialRetry{}, fmt.Errorf("%s must not be negative", retryDelayOptionName)
+	}
+	return p2p.DialRetry{Retries: retries, Delay: delay}, nil
+}
+
 // forwardLocal forwards local connections to a libp2p service
-func forwardLocal(ctx context.Context, p *p2p.P2P, ps pstore.Peerstore, proto protocol.ID, bindAddr ma.Multiaddr, addr *peer.AddrInfo) error {
+func forwardLocal(ctx context.Context, p *p2p.P2P, ps pstore.Peerstore, proto protocol.ID, bindAddr ma.Multiaddr, addr *peer.AddrInfo, udp bool, opts ...p2p.ForwardOption) error {
 	ps.AddAddrs(addr.ID, addr.Addrs, pstore.TempAddrTTL)
 	// TODO: return some info
-	_, err := p.ForwardLocal(ctx, addr.ID, proto, bindAddr)
+	var err error
+	if udp {
+		_, err = p.ForwardLocalUDP(ctx, addr.ID, proto, bindAddr, opts...)
+	} else {
+		_, err = p.ForwardLocal(ctx, addr.ID, proto, bindAddr, opts...)
+	}
 	return err
 }
 
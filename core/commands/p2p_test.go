@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIpfsAddr(t *testing.T) {
+	const pid = "12D3KooWGzxzKZYveHXtpG6AsrUJBcWxHBFS2HsEoGTxrMLvKXtf"
+	id, err := peer.Decode(pid)
+	require.NoError(t, err)
+
+	for _, addr := range []string{
+		"",
+		"/p2p/not-a-peer-id",
+		"/ip4/127.0.0.1/tcp/4001",
+	} {
+		_, err := parseIpfsAddr(addr)
+		require.ErrorContains(t, err, "invalid peer address", addr)
+	}
+
+	info, err := parseIpfsAddr("/p2p/" + pid)
+	require.NoError(t, err)
+	require.Equal(t, id, info.ID)
+	require.Empty(t, info.Addrs)
+
+	info, err = parseIpfsAddr("/ip4/127.0.0.1/tcp/4001/p2p/" + pid)
+	require.NoError(t, err)
+	require.Equal(t, id, info.ID)
+	require.Equal(t, []ma.Multiaddr{ma.StringCast("/ip4/127.0.0.1/tcp/4001")}, info.Addrs)
+}
+
+func TestCheckDialable(t *testing.T) {
+	remote, err := peer.Decode("12D3KooWRBy97UB99e3J6hiPesre1MZeuNQvfan4gBziswrRJsNK")
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name  string
+		info  peer.AddrInfo
+		valid bool
+	}{
+		{"peer only", peer.AddrInfo{ID: remote}, true},
+		{"public address", peer.AddrInfo{ID: remote, Addrs: []ma.Multiaddr{ma.StringCast("/ip4/1.2.3.4/tcp/4001")}}, true},
+		{"public and loopback addresses", peer.AddrInfo{ID: remote, Addrs: []ma.Multiaddr{ma.StringCast("/ip4/127.0.0.1/tcp/4001"), ma.StringCast("/dns4/example.com/tcp/4001")}}, true},
+		{"loopback address", peer.AddrInfo{ID: remote, Addrs: []ma.Multiaddr{ma.StringCast("/ip4/127.0.0.1/tcp/4001"), ma.StringCast("/ip6/::1/udp/4001/quic-v1")}}, true},
+		{"unspecified and loopback addresses", peer.AddrInfo{ID: remote, Addrs: []ma.Multiaddr{ma.StringCast("/ip4/0.0.0.0/tcp/4001"), ma.StringCast("/ip4/127.0.0.1/tcp/4001")}}, true},
+		{"unspecified addresses", peer.AddrInfo{ID: remote, Addrs: []ma.Multiaddr{ma.StringCast("/ip4/0.0.0.0/tcp/4001"), ma.StringCast("/ip6/::/tcp/4001")}}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkDialable(&tc.info)
+			if tc.valid {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, "invalid peer address")
+			}
+		})
+	}
+}
@@ -14,6 +14,7 @@ import (
 	oldcmds "github.com/ipfs/kubo/commands"
 	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
 	corerepo "github.com/ipfs/kubo/core/corerepo"
+	"github.com/ipfs/kubo/gc"
 	fsrepo "github.com/ipfs/kubo/repo/fsrepo"
 	"github.com/ipfs/kubo/repo/fsrepo/migrations"
 	"github.com/ipfs/kubo/repo/fsrepo/migrations/ipfsfetcher"
@@ -57,6 +58,7 @@ const (
 	repoQuietOptionName          = "quiet"
 	repoSilentOptionName         = "silent"
 	repoAllowDowngradeOptionName = "allow-downgrade"
+	repoDryRunOptionName         = "dry-run"
 )
 
 var repoGcCmd = &cmds.Command{
@@ -66,12 +68,16 @@ var repoGcCmd = &cmds.Command{
 'ipfs repo gc' is a plumbing command that will sweep the local
 set of stored objects and remove ones that are not pinned in
 order to reclaim hard disk space.
+
+With --dry-run, the objects that would be removed are listed but
+nothing is removed.
 `,
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption(repoStreamErrorsOptionName, "Stream errors."),
 		cmds.BoolOption(repoQuietOptionName, "q", "Write minimal output."),
 		cmds.BoolOption(repoSilentOptionName, "Write no output."),
+		cmds.BoolOption(repoDryRunOptionName, "List the objects that would be removed, without removing them."),
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		n, err := cmdenv.GetNode(env)
@@ -82,7 +88,12 @@ order to reclaim hard disk space.
 		silent, _ := req.Options[repoSilentOptionName].(bool)
 		streamErrors, _ := req.Options[repoStreamErrorsOptionName].(bool)
 
-		gcOutChan := corerepo.GarbageCollectAsync(n, req.Context)
+		var gcOutChan <-chan gc.Result
+		if dryRun, _ := req.Options[repoDryRunOptionName].(bool); dryRun {
+			gcOutChan = corerepo.UnreachableAsync(n, req.Context)
+		} else {
+			gcOutChan = corerepo.GarbageCollectAsync(n, req.Context)
+		}
 
 		if streamErrors {
 			errs := false
@@ -134,6 +145,9 @@ order to reclaim hard disk space.
 			}
 
 			prefix := "removed "
+			if dryRun, _ := req.Options[repoDryRunOptionName].(bool); dryRun {
+				prefix = "would remove "
+			}
 			if quiet {
 				prefix = ""
 			}
@@ -221,18 +221,25 @@ func (api *CoreAPI) WithOptions(opts ...options.ApiOption) (coreiface.CoreAPI, e
 			return nil, fmt.Errorf("cannot specify negative resolve cache size")
 		}
 
-		nsOptions := []namesys.Option{
-			namesys.WithDatastore(subAPI.repo.Datastore()),
-			namesys.WithDNSResolver(subAPI.dnsResolver),
-			namesys.WithCache(cs),
-			namesys.WithMaxCacheTTL(cfg.Ipns.MaxCacheTTL.WithDefault(config.DefaultIpnsMaxCacheTTL)),
-		}
-
 		subAPI.routing = offlineroute.NewOfflineRouter(subAPI.repo.Datastore(), subAPI.recordValidator)
 
-		subAPI.namesys, err = namesys.NewNameSystem(subAPI.routing, nsOptions...)
-		if err != nil {
-			return nil, fmt.Errorf("error constructing namesys: %w", err)
+		// Keep the node's DisabledNameSystem when IPNS is turned off.
+		if cfg.Ipns.Enabled.WithDefault(config.DefaultIpnsEnabled) {
+			nsOptions := []namesys.Option{
+				namesys.WithDatastore(subAPI.repo.Datastore()),
+				namesys.WithDNSResolver(subAPI.dnsResolver),
+			}
+
+			subAPI.namesys, err = namesys.NewNameSystem(node.WithLifetimeCap(subAPI.routing, cfg.Ipns.AcceptedLifetime()), nsOptions...)
+			if err != nil {
+				return nil, fmt.Errorf("error constructing namesys: %w", err)
+			}
+			subAPI.namesys, err = node.WithIpnsCache(subAPI.namesys, cs,
+				cfg.Ipns.MinCacheTTL.WithDefault(config.DefaultIpnsMinCacheTTL),
+				cfg.Ipns.MaxCacheTTL.WithDefault(config.DefaultIpnsMaxCacheTTL))
+			if err != nil {
+				return nil, fmt.Errorf("error constructing namesys cache: %w", err)
+			}
 		}
 
 		subAPI.provider = provider.NewNoopProvider()
@@ -9,6 +9,8 @@ import (
 	"github.com/ipfs/boxo/ipns"
 	keystore "github.com/ipfs/boxo/keystore"
 	"github.com/ipfs/boxo/namesys"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/node"
 	"github.com/ipfs/kubo/tracing"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
@@ -96,7 +98,21 @@ func (api *NameAPI) Search(ctx context.Context, name string, opts ...caopts.Name
 
 	var resolver namesys.Resolver = api.namesys
 	if !options.Cache {
-		resolver, err = namesys.NewNameSystem(api.routing,
+		cfg, err := api.repo.Config()
+		if err != nil {
+			return nil, err
+		}
+		if !cfg.Ipns.Enabled.WithDefault(config.DefaultIpnsEnabled) {
+			return nil, node.ErrIpnsDisabled
+		}
+
+		// Same name system as the node's, minus the cache. Like the one
+		// built by WithOptions, offline APIs skip the trusted resolvers.
+		ipnsCfg := cfg.Ipns
+		if api.parentOpts.Offline || api.checkOnline(false) != nil {
+			ipnsCfg.Resolvers = nil
+		}
+		resolver, err = node.NewNameSystem(api.routing, &ipnsCfg,
 			namesys.WithDatastore(api.repo.Datastore()),
 			namesys.WithDNSResolver(api.dnsResolver))
 		if err != nil {
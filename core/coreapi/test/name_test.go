@@ -0,0 +1,58 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ipfs/boxo/ipns"
+	keystore "github.com/ipfs/boxo/keystore"
+	"github.com/ipfs/go-datastore"
+	syncds "github.com/ipfs/go-datastore/sync"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core"
+	"github.com/ipfs/kubo/core/coreapi"
+	"github.com/ipfs/kubo/core/coreiface/options"
+	"github.com/ipfs/kubo/core/node"
+	"github.com/ipfs/kubo/repo"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestNameResolveIpnsDisabled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := config.Config{}
+	c.Identity = config.Identity{PeerID: testPeerID}
+	c.Ipns.Enabled = config.False
+
+	n, err := core.NewNode(ctx, &core.BuildCfg{
+		Repo: &repo.Mock{
+			C: c,
+			D: syncds.MutexWrap(datastore.NewMapDatastore()),
+			K: keystore.NewMemKeystore(),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Close()
+
+	api, err := coreapi.NewCoreAPI(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pid, err := peer.Decode(testPeerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := ipns.NameFromPeer(pid).String()
+
+	for _, cache := range []bool{true, false} {
+		_, err := api.Name().Resolve(ctx, name, options.Name.Cache(cache))
+		if !errors.Is(err, node.ErrIpnsDisabled) {
+			t.Fatalf("cache=%t: expected %q, got %v", cache, node.ErrIpnsDisabled, err)
+		}
+	}
+}
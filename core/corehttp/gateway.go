@@ -135,17 +135,25 @@ func newGatewayBackend(n *core.IpfsNode) (gateway.IPFSBackend, error) {
 			return nil, fmt.Errorf("cannot specify negative resolve cache size")
 		}
 
-		nsOptions := []namesys.Option{
-			namesys.WithDatastore(n.Repo.Datastore()),
-			namesys.WithDNSResolver(n.DNSResolver),
-			namesys.WithCache(cs),
-			namesys.WithMaxCacheTTL(cfg.Ipns.MaxCacheTTL.WithDefault(config.DefaultIpnsMaxCacheTTL)),
-		}
-
 		vsRouting = offlineroute.NewOfflineRouter(n.Repo.Datastore(), n.RecordValidator)
-		nsys, err = namesys.NewNameSystem(vsRouting, nsOptions...)
-		if err != nil {
-			return nil, fmt.Errorf("error constructing namesys: %w", err)
+
+		// Keep the node's DisabledNameSystem when IPNS is turned off.
+		if cfg.Ipns.Enabled.WithDefault(config.DefaultIpnsEnabled) {
+			nsOptions := []namesys.Option{
+				namesys.WithDatastore(n.Repo.Datastore()),
+				namesys.WithDNSResolver(n.DNSResolver),
+			}
+
+			nsys, err = namesys.NewNameSystem(node.WithLifetimeCap(vsRouting, cfg.Ipns.AcceptedLifetime()), nsOptions...)
+			if err != nil {
+				return nil, fmt.Errorf("error constructing namesys: %w", err)
+			}
+			nsys, err = node.WithIpnsCache(nsys, cs,
+				cfg.Ipns.MinCacheTTL.WithDefault(config.DefaultIpnsMinCacheTTL),
+				cfg.Ipns.MaxCacheTTL.WithDefault(config.DefaultIpnsMaxCacheTTL))
+			if err != nil {
+				return nil, fmt.Errorf("error constructing namesys cache: %w", err)
+			}
 		}
 
 		// Gateway.NoFetch=true requires offline path resolver
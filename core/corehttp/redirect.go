@@ -1,39 +1,337 @@
 package corehttp
 
 import (
+	"fmt"
 	"net"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 
+	"github.com/ipfs/boxo/path"
 	core "github.com/ipfs/kubo/core"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
-func RedirectOption(path string, redirect string) ServeOption {
+// RedirectOption redirects requests under /path/ to redirect. The part of
+// the request path after /path/ is appended to redirect, and so is the
+// query string: with RedirectOption("old", "/new"), /old/foo?bar=1 is
+// redirected to /new/foo?bar=1.
+//
+// code is the 3xx status of the redirects, 0 defaults to 302 (Found).
+//
+// Requests are only redirected to the host they were sent to: absolute
+// redirect URLs on another host are refused with 400 Bad Request unless the
+// rule is set up with WithExternalRedirects. When redirect is a path on the
+// same origin, requests that would be sent to another host once the request
+// path is appended (such as "//evil.example") are always refused.
+//
+// A path of the form "host:<host>/<path>" only redirects requests whose Host
+// header is <host> (ignoring the port). Such rules take precedence over rules
+// without a host for the same path, and other hosts are not affected by them:
+// with RedirectOption("host:old.example.com/docs", "https://new.example.com/docs"),
+// old.example.com/docs/a is redirected to https://new.example.com/docs/a.
+//
+// settings apply to this rule only, see WithRedirectStats and
+// WithExternalRedirects.
+func RedirectOption(path string, redirect string, code int, settings ...RedirectSetting) ServeOption {
+	return redirectOption(path, redirect, code, false, settings)
+}
+
+// ExactRedirectOption redirects every request under /path/ to redirect
+// itself, dropping the rest of the request path and its query string. This
+// is meant for landing pages. code and settings are handled as in
+// RedirectOption.
+func ExactRedirectOption(path string, redirect string, code int, settings ...RedirectSetting) ServeOption {
+	return redirectOption(path, redirect, code, true, settings)
+}
+
+// RedirectSetting changes how a single redirect rule behaves.
+type RedirectSetting func(*redirectHandler) error
+
+// WithRedirectStats counts the requests answered by the rule. The counts are
+// returned by RedirectStats and exported as the ipfs_http_redirects_total
+// metric. Rules are not counted by default.
+func WithRedirectStats() RedirectSetting {
+	return func(h *redirectHandler) error {
+		hits, err := redirectHits(h.rule)
+		if err != nil {
+			return err
+		}
+		h.hits = hits
+		return nil
+	}
+}
+
+// WithExternalRedirects allows the rule to redirect to absolute URLs on other
+// hosts. Only use it for trusted redirect targets, such as ones set by the
+// node operator, as it otherwise opens the gateway to open redirects.
+func WithExternalRedirects() RedirectSetting {
+	return func(h *redirectHandler) error {
+		h.external = true
+		return nil
+	}
+}
+
+func redirectOption(path string, redirect string, code int, exact bool, settings []RedirectSetting) ServeOption {
 	return func(n *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
-		cfg, err := n.Repo.Config()
+		if code == 0 {
+			code = http.StatusFound
+		}
+		if code < 300 || code > 399 {
+			return nil, fmt.Errorf("invalid redirect status code %d, must be 3xx", code)
+		}
+
+		target, err := redirectTarget(redirect)
+		if err != nil {
+			return nil, err
+		}
+
+		host, path, err := splitRedirectHost(path)
 		if err != nil {
 			return nil, err
 		}
 
-		handler := &redirectHandler{redirect, cfg.API.HTTPHeaders}
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			return nil, err
+		}
 
+		prefix := "/"
 		if len(path) > 0 {
-			mux.Handle("/"+path+"/", handler)
-		} else {
-			mux.Handle("/", handler)
+			prefix = "/" + path + "/"
+		}
+
+		handler := &redirectHandler{
+			rule:    host + prefix,
+			path:    target,
+			prefix:  prefix,
+			exact:   exact,
+			code:    code,
+			headers: cfg.API.HTTPHeaders,
 		}
+		for _, setting := range settings {
+			if err := setting(handler); err != nil {
+				return nil, err
+			}
+		}
+		mux.Handle(handler.rule, handler)
+		return mux, nil
+	}
+}
+
+// redirectStats holds the hit counters of the rules set up with
+// WithRedirectStats, by rule pattern (host and path prefix). Rules with the
+// same pattern share their counter.
+var redirectStats struct {
+	sync.Mutex
+	hits map[string]*atomic.Uint64
+}
+
+// RedirectStats returns the number of requests answered by each redirect rule
+// set up with WithRedirectStats, by rule pattern (host and path prefix).
+func RedirectStats() map[string]uint64 {
+	redirectStats.Lock()
+	defer redirectStats.Unlock()
+
+	stats := make(map[string]uint64, len(redirectStats.hits))
+	for rule, hits := range redirectStats.hits {
+		stats[rule] = hits.Load()
+	}
+	return stats
+}
+
+// redirectHits returns the hit counter of rule, registering it and its
+// ipfs_http_redirects_total metric on first use.
+func redirectHits(rule string) (*atomic.Uint64, error) {
+	redirectStats.Lock()
+	defer redirectStats.Unlock()
+
+	if hits, ok := redirectStats.hits[rule]; ok {
+		return hits, nil
+	}
+	hits := new(atomic.Uint64)
+	metric := prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace:   "ipfs",
+		Subsystem:   "http",
+		Name:        "redirects_total",
+		Help:        "Total number of HTTP requests redirected, by redirect rule.",
+		ConstLabels: prometheus.Labels{"rule": rule},
+	}, func() float64 {
+		return float64(hits.Load())
+	})
+	if err := prometheus.Register(metric); err != nil {
+		return nil, err
+	}
+	if redirectStats.hits == nil {
+		redirectStats.hits = make(map[string]*atomic.Uint64)
+	}
+	redirectStats.hits[rule] = hits
+	return hits, nil
+}
+
+// splitRedirectHost splits the host out of a "host:<host>/<path>" redirect
+// path. Paths without the "host:" prefix are returned unchanged, with an
+// empty host.
+func splitRedirectHost(p string) (host, rest string, err error) {
+	hostRule, ok := strings.CutPrefix(p, "host:")
+	if !ok {
+		return "", p, nil
+	}
+	host, rest, _ = strings.Cut(hostRule, "/")
+	if host == "" {
+		return "", "", fmt.Errorf("invalid redirect path %q: missing host", p)
+	}
+	return strings.ToLower(host), rest, nil
+}
+
+// redirectTarget validates content paths (/ipfs/ and /ipns/ targets) so that
+// a malformed CID is reported when the option is set up rather than when the
+// redirect is followed. Other targets are returned as is.
+func redirectTarget(redirect string) (string, error) {
+	if !strings.HasPrefix(redirect, "/ipfs/") && !strings.HasPrefix(redirect, "/ipns/") {
+		return redirect, nil
+	}
+	// Only the path is a content path, the target may have a query too.
+	u, err := url.Parse(redirect)
+	if err != nil {
+		return "", fmt.Errorf("invalid redirect target %q: %w", redirect, err)
+	}
+	p, err := path.NewPath(u.Path)
+	if err != nil {
+		return "", fmt.Errorf("invalid redirect target %q: %w", redirect, err)
+	}
+	u.Path, u.RawPath = p.String(), ""
+	return u.String(), nil
+}
+
+// forceHTTPSExemptPaths are served over plaintext by ForceHTTPSOption, so
+// that health checks and metric scrapers do not have to follow redirects.
+var forceHTTPSExemptPaths = []string{"/version", "/debug/metrics/prometheus"}
+
+// ForceHTTPSOption permanently redirects requests received over plaintext to
+// the same host and path on https, on the default port as the port of the
+// plaintext listener can't serve TLS. A request counts as received over TLS when
+// it was, or when it carries an "X-Forwarded-Proto: https" header. Only use
+// this option behind a reverse proxy that sets (or strips) that header:
+// otherwise any client can set it to be served over plaintext.
+//
+// It wraps all the handlers registered after it, so it should be passed
+// before the other options, RedirectOption included.
+func ForceHTTPSOption() ServeOption {
+	return func(n *core.IpfsNode, _ net.Listener, parent *http.ServeMux) (*http.ServeMux, error) {
+		mux := http.NewServeMux()
+		parent.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+				mux.ServeHTTP(w, r)
+				return
+			}
+			for _, p := range forceHTTPSExemptPaths {
+				if r.URL.Path == p {
+					mux.ServeHTTP(w, r)
+					return
+				}
+			}
+			host := r.Host
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+				if strings.Contains(host, ":") {
+					host = "[" + host + "]"
+				}
+			}
+			http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+		})
 		return mux, nil
 	}
 }
 
 type redirectHandler struct {
+	rule    string
 	path    string
+	prefix  string
+	exact   bool
+	code    int
 	headers map[string][]string
+
+	// external allows redirects to other hosts, see WithExternalRedirects.
+	external bool
+
+	// hits is nil unless the rule is counted, see WithRedirectStats.
+	hits *atomic.Uint64
 }
 
 func (i *redirectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	location := i.location(r)
+	if isLocalPath(i.path) && !isLocalPath(location) {
+		// The request path turned a same-origin target into a link to
+		// another host (e.g. "//evil.example"), don't follow it.
+		log.Warnf("refusing cross-origin redirect of %q to %q", r.URL.RequestURI(), location)
+		http.Error(w, "invalid redirect target", http.StatusBadRequest)
+		return
+	}
+	if !i.external && !isSameHost(location, r) {
+		log.Warnf("refusing redirect of %q to %q on another host", r.URL.RequestURI(), location)
+		http.Error(w, "invalid redirect target", http.StatusBadRequest)
+		return
+	}
+
+	if i.hits != nil {
+		i.hits.Add(1)
+	}
 	for k, v := range i.headers {
 		w.Header()[http.CanonicalHeaderKey(k)] = v
 	}
 
-	http.Redirect(w, r, i.path, http.StatusFound)
+	http.Redirect(w, r, location, i.code)
+}
+
+// isLocalPath reports whether target is a path on the same origin, rather
+// than an absolute or protocol-relative URL pointing to some other host.
+// Browsers treat a backslash like a slash, so "/\host" is not local either.
+func isLocalPath(target string) bool {
+	if !strings.HasPrefix(target, "/") {
+		return false
+	}
+	return len(target) == 1 || (target[1] != '/' && target[1] != '\\')
+}
+
+// isSameHost reports whether target points to the host r was sent to.
+// Relative targets always do, absolute and protocol-relative URLs only when
+// their host is the Host of r.
+func isSameHost(target string, r *http.Request) bool {
+	if isLocalPath(target) {
+		return true
+	}
+	// Browsers treat a backslash like a slash, see isLocalPath.
+	u, err := url.Parse(strings.ReplaceAll(target, "\\", "/"))
+	if err != nil {
+		return false
+	}
+	if u.Scheme == "" && u.Host == "" {
+		return true
+	}
+	return u.Host != "" && strings.EqualFold(u.Host, r.Host)
+}
+
+// location returns where r is redirected to.
+func (i *redirectHandler) location(r *http.Request) string {
+	if i.exact {
+		return i.path
+	}
+
+	target, query, _ := strings.Cut(i.path, "?")
+	if rest := strings.TrimPrefix(r.URL.EscapedPath(), i.prefix); rest != "" {
+		target = strings.TrimSuffix(target, "/") + "/" + rest
+	}
+	if r.URL.RawQuery != "" {
+		if query != "" {
+			query += "&"
+		}
+		query += r.URL.RawQuery
+	}
+	if query != "" {
+		target += "?" + query
+	}
+	return target
 }
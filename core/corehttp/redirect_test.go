@@ -0,0 +1,285 @@
+package corehttp
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	core "github.com/ipfs/kubo/core"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedirectOption(t *testing.T) {
+	n, err := newNodeWithMockNamesys(mockNamesys{})
+	require.NoError(t, err)
+
+	for _, target := range []string{
+		"/ipfs/bafkqaaa/index.html",
+		"/ipfs/bafkqaaa?filename=hello.txt",
+		"/ipns/example.net",
+		"https://example.net/",
+	} {
+		mux, err := RedirectOption("", target, 0, WithExternalRedirects())(n, nil, http.NewServeMux())
+		require.NoError(t, err, target)
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Equal(t, http.StatusFound, w.Code)
+		require.Equal(t, target, w.Header().Get("Location"))
+	}
+
+	for _, target := range []string{
+		"/ipfs/not-a-cid",
+		"/ipfs/not-a-cid?filename=hello.txt",
+		"/ipfs/",
+		"/ipns/",
+	} {
+		_, err := RedirectOption("", target, 0)(n, nil, http.NewServeMux())
+		require.Error(t, err, target)
+	}
+}
+
+func TestRedirectOptionSuffix(t *testing.T) {
+	n, err := newNodeWithMockNamesys(mockNamesys{})
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		option   ServeOption
+		uri      string
+		location string
+	}{
+		{RedirectOption("old", "/new", 0), "/old/", "/new"},
+		{RedirectOption("old", "/new", 0), "/old/foo/bar?baz=1", "/new/foo/bar?baz=1"},
+		{RedirectOption("old", "/new/", 0), "/old/foo", "/new/foo"},
+		{RedirectOption("old", "https://example.net/new?a=1", 0, WithExternalRedirects()), "/old/foo?b=2", "https://example.net/new/foo?a=1&b=2"},
+		{RedirectOption("", "/ipfs/bafkqaaa", 0), "/foo%20bar", "/ipfs/bafkqaaa/foo%20bar"},
+		{RedirectOption("old", "/ipfs/bafkqaaa?format=raw", 0), "/old/?download=true", "/ipfs/bafkqaaa?format=raw&download=true"},
+		{ExactRedirectOption("old", "/new", 0), "/old/foo?bar=1", "/new"},
+	} {
+		mux, err := tc.option(n, nil, http.NewServeMux())
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.uri, nil))
+		require.Equal(t, http.StatusFound, w.Code, tc.uri)
+		require.Equal(t, tc.location, w.Header().Get("Location"), tc.uri)
+	}
+}
+
+func TestRedirectOptionHost(t *testing.T) {
+	n, err := newNodeWithMockNamesys(mockNamesys{})
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	for _, option := range []ServeOption{
+		RedirectOption("docs", "/ipns/docs.example.net", 0),
+		RedirectOption("host:old.example.com/docs", "https://new.example.com/docs", 0, WithExternalRedirects()),
+		RedirectOption("host:Old.Example.com/blog", "https://new.example.com/blog", 0, WithExternalRedirects()),
+	} {
+		mux, err = option(n, nil, mux)
+		require.NoError(t, err)
+	}
+
+	for _, tc := range []struct {
+		host     string
+		uri      string
+		code     int
+		location string
+	}{
+		{"old.example.com", "/docs/a?b=1", http.StatusFound, "https://new.example.com/docs/a?b=1"},
+		{"old.example.com:8080", "/docs/", http.StatusFound, "https://new.example.com/docs"},
+		{"other.example.com", "/docs/a", http.StatusFound, "/ipns/docs.example.net/a"},
+		{"old.example.com", "/blog/post", http.StatusFound, "https://new.example.com/blog/post"},
+		{"other.example.com", "/blog/post", http.StatusNotFound, ""},
+	} {
+		r := httptest.NewRequest(http.MethodGet, tc.uri, nil)
+		r.Host = tc.host
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		require.Equal(t, tc.code, w.Code, tc.host+tc.uri)
+		require.Equal(t, tc.location, w.Header().Get("Location"), tc.host+tc.uri)
+	}
+
+	_, err = RedirectOption("host:/docs", "/new", 0)(n, nil, http.NewServeMux())
+	require.Error(t, err)
+}
+
+func TestRedirectOptionStats(t *testing.T) {
+	n, err := newNodeWithMockNamesys(mockNamesys{})
+	require.NoError(t, err)
+
+	before := RedirectStats()
+
+	mux := http.NewServeMux()
+	for _, option := range []ServeOption{
+		RedirectOption("hits", "/new", 0, WithRedirectStats()),
+		RedirectOption("host:hits.example.com/hits", "/other", 0, WithRedirectStats()),
+		RedirectOption("uncounted", "/new", 0),
+	} {
+		mux, err = option(n, nil, mux)
+		require.NoError(t, err)
+	}
+
+	var wg sync.WaitGroup
+	for _, uri := range []string{"example.com/hits/a", "example.com/hits/b", "hits.example.com/hits/a", "example.com/hits/", "example.com/uncounted/a", "example.com/unmatched"} {
+		wg.Add(1)
+		go func(uri string) {
+			defer wg.Done()
+			host, p, _ := strings.Cut(uri, "/")
+			r := httptest.NewRequest(http.MethodGet, "/"+p, nil)
+			r.Host = host
+			mux.ServeHTTP(httptest.NewRecorder(), r)
+		}(uri)
+	}
+	wg.Wait()
+
+	stats := RedirectStats()
+	require.Equal(t, before["/hits/"]+3, stats["/hits/"])
+	require.Equal(t, before["hits.example.com/hits/"]+1, stats["hits.example.com/hits/"])
+	require.NotContains(t, stats, "/uncounted/")
+
+	// The counts are exported to Prometheus as well.
+	metrics, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	var exported float64
+	for _, mf := range metrics {
+		if mf.GetName() != "ipfs_http_redirects_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "rule" && l.GetValue() == "/hits/" {
+					exported = m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	require.Equal(t, float64(stats["/hits/"]), exported)
+}
+
+func TestRedirectCrossOrigin(t *testing.T) {
+	for _, tc := range []struct {
+		target   string
+		external bool
+		path     string
+		code     int
+		location string
+	}{
+		{"/", false, "/old/foo", http.StatusFound, "/foo"},
+		{"/new", false, "/old//evil.example", http.StatusFound, "/new/evil.example"},
+		{"/", false, "/old//evil.example", http.StatusBadRequest, ""},
+		{"/", true, "/old//evil.example", http.StatusBadRequest, ""},
+		{"/", false, "/old/\\evil.example", http.StatusFound, "/%5Cevil.example"},
+		{"https://example.net", true, "/old//evil.example", http.StatusFound, "https://example.net//evil.example"},
+	} {
+		// Call the handler directly, the ServeMux would clean the path.
+		h := &redirectHandler{path: tc.target, external: tc.external, prefix: "/old/", code: http.StatusFound}
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.URL.Path = tc.path
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		require.Equal(t, tc.code, w.Code, tc.path)
+		require.Equal(t, tc.location, w.Header().Get("Location"), tc.path)
+	}
+}
+
+func TestRedirectExternal(t *testing.T) {
+	n, err := newNodeWithMockNamesys(mockNamesys{})
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		target   string
+		external bool
+		code     int
+	}{
+		// Targets on the same host are always allowed.
+		{"/new", false, http.StatusFound},
+		{"new", false, http.StatusFound},
+		{"/ipfs/bafkqaaa", false, http.StatusFound},
+		{"https://example.com/new", false, http.StatusFound},
+		{"//Example.com/new", false, http.StatusFound},
+		// Other hosts and schemes need WithExternalRedirects.
+		{"https://evil.example", false, http.StatusBadRequest},
+		{"https://example.com:8443/new", false, http.StatusBadRequest},
+		{"//evil.example/new", false, http.StatusBadRequest},
+		{"\\\\evil.example/new", false, http.StatusBadRequest},
+		{"javascript:alert(1)", false, http.StatusBadRequest},
+		{"https://evil.example", true, http.StatusFound},
+	} {
+		var settings []RedirectSetting
+		if tc.external {
+			settings = append(settings, WithExternalRedirects())
+		}
+		mux, err := ExactRedirectOption("old", tc.target, 0, settings...)(n, nil, http.NewServeMux())
+		require.NoError(t, err, tc.target)
+
+		r := httptest.NewRequest(http.MethodGet, "/old/", nil)
+		r.Host = "example.com"
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		require.Equal(t, tc.code, w.Code, tc.target)
+		if tc.code == http.StatusBadRequest {
+			require.Empty(t, w.Header().Get("Location"), tc.target)
+		}
+	}
+}
+
+func TestRedirectOptionCode(t *testing.T) {
+	n, err := newNodeWithMockNamesys(mockNamesys{})
+	require.NoError(t, err)
+
+	for _, code := range []int{http.StatusMovedPermanently, http.StatusTemporaryRedirect, http.StatusPermanentRedirect} {
+		mux, err := RedirectOption("", "/new", code)(n, nil, http.NewServeMux())
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", nil))
+		require.Equal(t, code, w.Code)
+		require.Equal(t, "/new", w.Header().Get("Location"))
+	}
+
+	for _, code := range []int{http.StatusOK, http.StatusNotFound, 1} {
+		_, err := RedirectOption("", "/new", code)(n, nil, http.NewServeMux())
+		require.Error(t, err, code)
+	}
+}
+
+func TestForceHTTPSOption(t *testing.T) {
+	teapot := func(_ *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		})
+		return mux, nil
+	}
+	handler, err := MakeHandler(nil, nil, ForceHTTPSOption(), teapot)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name     string
+		req      *http.Request
+		code     int
+		location string
+	}{
+		{"plaintext", httptest.NewRequest(http.MethodGet, "http://example.net/ipfs/bafkqaaa?format=raw", nil), http.StatusMovedPermanently, "https://example.net/ipfs/bafkqaaa?format=raw"},
+		{"plaintext port", httptest.NewRequest(http.MethodGet, "http://example.net:8080/ipfs/bafkqaaa", nil), http.StatusMovedPermanently, "https://example.net/ipfs/bafkqaaa"},
+		{"plaintext ipv6 port", httptest.NewRequest(http.MethodGet, "http://[::1]:8080/ipfs/bafkqaaa", nil), http.StatusMovedPermanently, "https://[::1]/ipfs/bafkqaaa"},
+		{"tls", httptest.NewRequest(http.MethodGet, "https://example.net/ipfs/bafkqaaa", nil), http.StatusTeapot, ""},
+		{"health check", httptest.NewRequest(http.MethodGet, "http://example.net/version", nil), http.StatusTeapot, ""},
+		{"forwarded https", func() *http.Request {
+			r := httptest.NewRequest(http.MethodGet, "http://example.net/", nil)
+			r.Header.Set("X-Forwarded-Proto", "https")
+			return r
+		}(), http.StatusTeapot, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, tc.req)
+			require.Equal(t, tc.code, w.Code)
+			require.Equal(t, tc.location, w.Header().Get("Location"))
+		})
+	}
+}
@@ -1,5 +1,7 @@
 package corehttp
 
+import "net/http"
+
 // WebUI version confirmed to work with this Kubo version
 const WebUIPath = "/ipfs/bafybeihatzsgposbr3hrngo42yckdyqcc56yean2rynnwpzxstvdlphxf4" // v4.3.0
 
@@ -63,4 +65,4 @@ var WebUIPaths = []string{
 	"/ipfs/Qmexhq2sBHnXQbvyP2GfUdbnY7HCagH2Mw5vUNSBn2nxip",
 }
 
-var WebUIOption = RedirectOption("webui", WebUIPath)
+var WebUIOption = ExactRedirectOption("webui", WebUIPath, http.StatusFound)
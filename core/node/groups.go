@@ -242,30 +242,24 @@ func Online(bcfg *BuildCfg, cfg *config.Config, userResourceOverrides rcmgr.Part
 		return fx.Error(fmt.Errorf("cannot specify negative resolve cache size"))
 	}
 
-	// Republisher params
+	// Republisher params, unused when IPNS is disabled
 
+	ipnsEnabled := cfg.Ipns.Enabled.WithDefault(config.DefaultIpnsEnabled)
 	var repubPeriod, recordLifetime time.Duration
-
-	if cfg.Ipns.RepublishPeriod != "" {
-		d, err := time.ParseDuration(cfg.Ipns.RepublishPeriod)
+	if ipnsEnabled {
+		var err error
+		repubPeriod, err = cfg.Ipns.RepublishInterval()
 		if err != nil {
-			return fx.Error(fmt.Errorf("failure to parse config setting IPNS.RepublishPeriod: %s", err))
+			return fx.Error(err)
 		}
-
-		if !util.Debug && (d < time.Minute || d > (time.Hour*24)) {
-			return fx.Error(fmt.Errorf("config setting IPNS.RepublishPeriod is not between 1min and 1day: %s", d))
+		if !util.Debug && (repubPeriod < time.Minute || repubPeriod > (time.Hour*24)) {
+			return fx.Error(fmt.Errorf("config setting IPNS.RepublishPeriod is not between 1min and 1day: %s", repubPeriod))
 		}
 
-		repubPeriod = d
-	}
-
-	if cfg.Ipns.RecordLifetime != "" {
-		d, err := time.ParseDuration(cfg.Ipns.RecordLifetime)
+		recordLifetime, err = cfg.Ipns.Lifetime()
 		if err != nil {
-			return fx.Error(fmt.Errorf("failure to parse config setting IPNS.RecordLifetime: %s", err))
+			return fx.Error(err)
 		}
-
-		recordLifetime = d
 	}
 
 	/* don't provide from bitswap when the strategic provider service is active */
@@ -275,11 +269,11 @@ func Online(bcfg *BuildCfg, cfg *config.Config, userResourceOverrides rcmgr.Part
 		fx.Provide(BitswapOptions(cfg, shouldBitswapProvide)),
 		fx.Provide(OnlineExchange()),
 		fx.Provide(DNSResolver),
-		fx.Provide(Namesys(ipnsCacheSize, cfg.Ipns.MaxCacheTTL.WithDefault(config.DefaultIpnsMaxCacheTTL))),
+		fx.Provide(Namesys(ipnsEnabled, ipnsCacheSize, cfg.Ipns.MaxCacheTTL.WithDefault(config.DefaultIpnsMaxCacheTTL), cfg.Ipns.MinCacheTTL.WithDefault(config.DefaultIpnsMinCacheTTL), &cfg.Ipns)),
 		fx.Provide(Peering),
 		PeerWith(cfg.Peering.Peers...),
 
-		fx.Invoke(IpnsRepublisher(repubPeriod, recordLifetime)),
+		maybeInvoke(IpnsRepublisher(repubPeriod, recordLifetime), ipnsEnabled),
 
 		fx.Provide(p2p.New),
 
@@ -298,7 +292,7 @@ func Offline(cfg *config.Config) fx.Option {
 	return fx.Options(
 		fx.Provide(offline.Exchange),
 		fx.Provide(DNSResolver),
-		fx.Provide(Namesys(0, 0)),
+		fx.Provide(Namesys(cfg.Ipns.Enabled.WithDefault(config.DefaultIpnsEnabled), 0, 0, 0, nil)),
 		fx.Provide(libp2p.Routing),
 		fx.Provide(libp2p.ContentRouting),
 		fx.Provide(libp2p.OfflineRouting),
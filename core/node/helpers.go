@@ -43,7 +43,6 @@ func maybeProvide(opt interface{}, enable bool) fx.Option {
 	return fx.Options()
 }
 
-// nolint unused
 func maybeInvoke(opt interface{}, enable bool) fx.Option {
 	if enable {
 		return fx.Invoke(opt)
@@ -1,18 +1,24 @@
 package node
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/boxo/path"
 	util "github.com/ipfs/boxo/util"
 	record "github.com/libp2p/go-libp2p-record"
 	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/routing"
 	madns "github.com/multiformats/go-multiaddr-dns"
 
 	"github.com/ipfs/boxo/namesys"
 	"github.com/ipfs/boxo/namesys/republisher"
+	"github.com/ipfs/kubo/config"
 	"github.com/ipfs/kubo/repo"
 	irouting "github.com/ipfs/kubo/routing"
 )
@@ -27,21 +33,148 @@ func RecordValidator(ps peerstore.Peerstore) record.Validator {
 	}
 }
 
-// Namesys creates new name system
-func Namesys(cacheSize int, cacheMaxTTL time.Duration) func(rt irouting.ProvideManyRouter, rslv *madns.Resolver, repo repo.Repo) (namesys.NameSystem, error) {
+// errLifetimeExceeded is returned for IPNS records that expire further in the
+// future than Ipns.MaxAcceptedLifetime allows.
+var errLifetimeExceeded = errors.New("IPNS record lifetime exceeds Ipns.MaxAcceptedLifetime")
+
+// lifetimeCapValueStore treats IPNS records whose EOL lies more than
+// maxLifetime in the future as expired, so that publishers cannot pin stale
+// data in this node's caches with very long record lifetimes. It only wraps
+// the routing used to resolve names: the record validator is shared with the
+// DHT, which must keep storing and serving these records to other peers.
+type lifetimeCapValueStore struct {
+	routing.ValueStore
+
+	maxLifetime time.Duration
+}
+
+// WithLifetimeCap wraps vs so that the IPNS records it returns never expire
+// more than maxLifetime from now. vs is returned unchanged when maxLifetime is
+// DefaultIpnsMaxAcceptedLifetime (no cap).
+func WithLifetimeCap(vs routing.ValueStore, maxLifetime time.Duration) routing.ValueStore {
+	if maxLifetime >= config.DefaultIpnsMaxAcceptedLifetime {
+		return vs
+	}
+	return &lifetimeCapValueStore{ValueStore: vs, maxLifetime: maxLifetime}
+}
+
+func (vs *lifetimeCapValueStore) GetValue(ctx context.Context, key string, opts ...routing.Option) ([]byte, error) {
+	val, err := vs.ValueStore.GetValue(ctx, key, opts...)
+	if err != nil || !strings.HasPrefix(key, ipns.NamespacePrefix) {
+		return val, err
+	}
+	if err := vs.checkLifetime(val); err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+func (vs *lifetimeCapValueStore) SearchValue(ctx context.Context, key string, opts ...routing.Option) (<-chan []byte, error) {
+	vals, err := vs.ValueStore.SearchValue(ctx, key, opts...)
+	if err != nil || !strings.HasPrefix(key, ipns.NamespacePrefix) {
+		return vals, err
+	}
+
+	out := make(chan []byte)
+	go func() {
+		defer close(out)
+		for val := range vals {
+			if err := vs.checkLifetime(val); err != nil {
+				logger.Debugf("skipping IPNS record: %s", err)
+				continue
+			}
+			select {
+			case out <- val:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// checkLifetime returns errLifetimeExceeded when the IPNS record in val
+// expires more than maxLifetime from now.
+func (vs *lifetimeCapValueStore) checkLifetime(val []byte) error {
+	rec, err := ipns.UnmarshalRecord(val)
+	if err != nil {
+		return err
+	}
+	eol, err := rec.Validity()
+	if err != nil {
+		return err
+	}
+	if time.Until(eol) > vs.maxLifetime {
+		return fmt.Errorf("%w: record valid until %s, cap is %s", errLifetimeExceeded, eol.UTC().Format(time.RFC3339), vs.maxLifetime)
+	}
+	return nil
+}
+
+// Namesys creates new name system, or DisabledNameSystem when enabled is
+// false. Trusted resolvers from ipnsCfg, when not nil, are consulted first and
+// its MaxAcceptedLifetime applies to every resolved record.
+func Namesys(enabled bool, cacheSize int, cacheMaxTTL, cacheMinTTL time.Duration, ipnsCfg *config.Ipns) func(rt irouting.ProvideManyRouter, rslv *madns.Resolver, repo repo.Repo) (namesys.NameSystem, error) {
 	return func(rt irouting.ProvideManyRouter, rslv *madns.Resolver, repo repo.Repo) (namesys.NameSystem, error) {
+		if !enabled {
+			return DisabledNameSystem{}, nil
+		}
+
 		opts := []namesys.Option{
 			namesys.WithDatastore(repo.Datastore()),
 			namesys.WithDNSResolver(rslv),
-			namesys.WithMaxCacheTTL(cacheMaxTTL),
 		}
 
-		if cacheSize > 0 {
-			opts = append(opts, namesys.WithCache(cacheSize))
+		ns, err := NewNameSystem(rt, ipnsCfg, opts...)
+		if err != nil {
+			return nil, err
 		}
+		return WithIpnsCache(ns, cacheSize, cacheMinTTL, cacheMaxTTL)
+	}
+}
 
-		return namesys.NewNameSystem(rt, opts...)
+// NewNameSystem creates the name system built by Namesys, minus the cache.
+// Records resolved through rt are subject to the MaxAcceptedLifetime of
+// ipnsCfg and its trusted Resolvers are consulted first. ipnsCfg may be nil.
+func NewNameSystem(rt routing.ValueStore, ipnsCfg *config.Ipns, opts ...namesys.Option) (namesys.NameSystem, error) {
+	if ipnsCfg != nil {
+		rt = WithLifetimeCap(rt, ipnsCfg.AcceptedLifetime())
+	}
+	ns, err := namesys.NewNameSystem(rt, opts...)
+	if err != nil {
+		return nil, err
 	}
+	return WithTrustedResolvers(ns, ipnsCfg)
+}
+
+// WithIpnsCache wraps ns with a CachedNameSystem when cacheSize is positive,
+// otherwise ns is returned as is.
+func WithIpnsCache(ns namesys.NameSystem, cacheSize int, cacheMinTTL, cacheMaxTTL time.Duration) (namesys.NameSystem, error) {
+	if cacheSize <= 0 {
+		return ns, nil
+	}
+	return NewCachedNameSystem(ns, cacheSize, cacheMinTTL, cacheMaxTTL)
+}
+
+// ErrIpnsDisabled is returned by DisabledNameSystem.
+var ErrIpnsDisabled = errors.New("IPNS is disabled (Ipns.Enabled is false)")
+
+// DisabledNameSystem is the name system used when Ipns.Enabled is false. It
+// fails every resolution and publication with ErrIpnsDisabled.
+type DisabledNameSystem struct{}
+
+func (DisabledNameSystem) Resolve(context.Context, path.Path, ...namesys.ResolveOption) (namesys.Result, error) {
+	return namesys.Result{}, ErrIpnsDisabled
+}
+
+func (DisabledNameSystem) ResolveAsync(context.Context, path.Path, ...namesys.ResolveOption) <-chan namesys.AsyncResult {
+	out := make(chan namesys.AsyncResult, 1)
+	out <- namesys.AsyncResult{Err: ErrIpnsDisabled}
+	close(out)
+	return out
+}
+
+func (DisabledNameSystem) Publish(context.Context, crypto.PrivKey, path.Path, ...namesys.PublishOption) error {
+	return ErrIpnsDisabled
 }
 
 // IpnsRepublisher runs new IPNS republisher service
@@ -0,0 +1,280 @@
+package node
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/boxo/namesys"
+	"github.com/ipfs/boxo/path"
+	ci "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// The name system caches of all nodes in the process report to the same
+// counters, the daemon exposes them on /debug/metrics/prometheus.
+var (
+	ipnsCacheHitsMetric = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "ipfs",
+		Subsystem: "name_cache",
+		Name:      "hits_total",
+		Help:      "Number of IPNS names resolved from the name system cache.",
+	})
+	ipnsCacheMissesMetric = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "ipfs",
+		Subsystem: "name_cache",
+		Name:      "misses_total",
+		Help:      "Number of IPNS names not found in the name system cache.",
+	})
+	ipnsCacheEvictionsMetric = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "ipfs",
+		Subsystem: "name_cache",
+		Name:      "evictions_total",
+		Help:      "Number of entries evicted from a full name system cache.",
+	})
+)
+
+// IpnsCacheStats reports the activity of a CachedNameSystem.
+type IpnsCacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+// CachedNameSystem is a namesys.NameSystem backed by an LRU cache holding up
+// to Ipns.ResolveCacheSize resolved names. Entries expire after their record
+// TTL, clamped into [Ipns.MinCacheTTL, Ipns.MaxCacheTTL].
+type CachedNameSystem struct {
+	namesys.NameSystem
+
+	minTTL time.Duration
+	maxTTL time.Duration
+
+	// mu serializes cache updates so that the lastMod of an existing entry
+	// can be carried over when it is refreshed.
+	mu    sync.Mutex
+	cache *lru.Cache[string, ipnsCacheEntry]
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+type ipnsCacheEntry struct {
+	val      path.Path
+	ttl      time.Duration
+	lastMod  time.Time
+	cacheEOL time.Time
+}
+
+// NewCachedNameSystem wraps ns, which should not have a cache of its own, with
+// an LRU cache of the given size.
+func NewCachedNameSystem(ns namesys.NameSystem, size int, minTTL, maxTTL time.Duration) (*CachedNameSystem, error) {
+	cache, err := lru.New[string, ipnsCacheEntry](size)
+	if err != nil {
+		return nil, err
+	}
+	return &CachedNameSystem{
+		NameSystem: ns,
+		minTTL:     minTTL,
+		maxTTL:     maxTTL,
+		cache:      cache,
+	}, nil
+}
+
+// CacheStats returns the number of cache hits, misses and evictions since the
+// name system was created. The totals over all caches of the process are
+// exported to Prometheus as ipfs_name_cache_*.
+func (ns *CachedNameSystem) CacheStats() IpnsCacheStats {
+	return IpnsCacheStats{
+		Hits:      ns.hits.Load(),
+		Misses:    ns.misses.Load(),
+		Evictions: ns.evictions.Load(),
+	}
+}
+
+func (ns *CachedNameSystem) Resolve(ctx context.Context, p path.Path, options ...namesys.ResolveOption) (namesys.Result, error) {
+	base, ok := cacheableBase(p, options)
+	if !ok {
+		return ns.NameSystem.Resolve(ctx, p, options...)
+	}
+
+	if entry, ok := ns.cacheGet(base.String()); ok {
+		resolved, err := joinUnresolved(entry.val, p)
+		return namesys.Result{Path: resolved, TTL: entry.ttl, LastMod: entry.lastMod}, err
+	}
+
+	res, err := ns.NameSystem.Resolve(ctx, base, options...)
+	if err != nil {
+		return res, err
+	}
+	ns.cacheSet(base.String(), res.Path, res.TTL, res.LastMod)
+
+	res.Path, err = joinUnresolved(res.Path, p)
+	return res, err
+}
+
+func (ns *CachedNameSystem) ResolveAsync(ctx context.Context, p path.Path, options ...namesys.ResolveOption) <-chan namesys.AsyncResult {
+	base, ok := cacheableBase(p, options)
+	if !ok {
+		return ns.NameSystem.ResolveAsync(ctx, p, options...)
+	}
+
+	out := make(chan namesys.AsyncResult, 1)
+	if entry, ok := ns.cacheGet(base.String()); ok {
+		resolved, err := joinUnresolved(entry.val, p)
+		out <- namesys.AsyncResult{Path: resolved, TTL: entry.ttl, LastMod: entry.lastMod, Err: err}
+		close(out)
+		return out
+	}
+
+	resCh := ns.NameSystem.ResolveAsync(ctx, base, options...)
+	go func() {
+		defer close(out)
+		var best namesys.AsyncResult
+		for res := range resCh {
+			if res.Err == nil {
+				best = res
+				res.Path, res.Err = joinUnresolved(res.Path, p)
+			}
+			select {
+			case out <- res:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if best.Path != nil {
+			ns.cacheSet(base.String(), best.Path, best.TTL, best.LastMod)
+		}
+	}()
+	return out
+}
+
+func (ns *CachedNameSystem) Publish(ctx context.Context, name ci.PrivKey, value path.Path, options ...namesys.PublishOption) error {
+	pid, err := peer.IDFromPrivateKey(name)
+	if err != nil {
+		return err
+	}
+	key := ipns.NameFromPeer(pid).AsPath().String()
+
+	// Same EOL for the record and the cache entry, see boxo namesys.Publish.
+	publishOpts := namesys.ProcessPublishOptions(options)
+	options = append(options, namesys.PublishWithEOL(publishOpts.EOL))
+
+	if err := ns.NameSystem.Publish(ctx, name, value, options...); err != nil {
+		// Publishing may partially succeed, drop whatever we had.
+		ns.cache.Remove(key)
+		return err
+	}
+
+	ttl := namesys.DefaultResolverCacheTTL
+	if publishOpts.TTL >= 0 {
+		ttl = publishOpts.TTL
+	}
+	if untilEOL := time.Until(publishOpts.EOL); untilEOL < ttl {
+		ttl = untilEOL
+	}
+	ns.cacheSet(key, value, ttl, time.Now())
+	return nil
+}
+
+func (ns *CachedNameSystem) cacheGet(key string) (ipnsCacheEntry, bool) {
+	entry, ok := ns.cache.Get(key)
+	if ok && time.Now().Before(entry.cacheEOL) {
+		ns.hits.Add(1)
+		ipnsCacheHitsMetric.Inc()
+		return entry, true
+	}
+	// Expired entries are kept until evicted, cacheSet uses them to carry
+	// over the lastMod of an unchanged value.
+	ns.misses.Add(1)
+	ipnsCacheMissesMetric.Inc()
+	return ipnsCacheEntry{}, false
+}
+
+func (ns *CachedNameSystem) cacheSet(key string, val path.Path, ttl time.Duration, lastMod time.Time) {
+	// Only fully resolved values are cached, partial resolutions (limited
+	// depth) would be wrong for recursive lookups.
+	if val == nil || val.Mutable() {
+		return
+	}
+
+	cacheTTL := ttl
+	if cacheTTL > ns.maxTTL {
+		cacheTTL = ns.maxTTL
+	}
+	if cacheTTL < ns.minTTL {
+		cacheTTL = ns.minTTL
+	}
+	if cacheTTL <= 0 {
+		return
+	}
+
+	if lastMod.IsZero() {
+		lastMod = time.Now()
+	}
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	// Keep the oldest lastMod when the value did not change.
+	if prev, ok := ns.cache.Peek(key); ok && prev.val.String() == val.String() && prev.lastMod.Before(lastMod) {
+		lastMod = prev.lastMod
+	}
+
+	evicted := ns.cache.Add(key, ipnsCacheEntry{
+		val:      val,
+		ttl:      ttl,
+		lastMod:  lastMod,
+		cacheEOL: time.Now().Add(cacheTTL),
+	})
+	if evicted {
+		ns.evictions.Add(1)
+		ipnsCacheEvictionsMetric.Inc()
+	}
+}
+
+// cacheableBase returns the /ipns/<name> prefix of p, which is what the cache
+// is keyed on. Key names are converted to their canonical form, the one
+// Publish uses, and DNSLink names are lowercased, so that all spellings of a
+// name share an entry. Immutable paths and lookups with a custom recursion
+// depth are not cacheable.
+func cacheableBase(p path.Path, options []namesys.ResolveOption) (path.Path, bool) {
+	if !p.Mutable() {
+		return nil, false
+	}
+	if depth := namesys.ProcessResolveOptions(options).Depth; depth != namesys.DefaultDepthLimit && depth != namesys.UnlimitedDepth {
+		return nil, false
+	}
+	segments := p.Segments()
+	if len(segments) < 2 {
+		return nil, false
+	}
+	if name, err := ipns.NameFromString(segments[1]); err == nil {
+		return name.AsPath(), true
+	}
+	base, err := path.NewPathFromSegments(segments[0], strings.ToLower(segments[1]))
+	if err != nil {
+		return nil, false
+	}
+	return base, true
+}
+
+// joinUnresolved appends the segments of unresolved past its /ipns/<name>
+// prefix to resolved.
+func joinUnresolved(resolved, unresolved path.Path) (path.Path, error) {
+	segments := unresolved.Segments()[2:]
+	if strings.HasSuffix(unresolved.String(), "/") {
+		segments = append(segments, "")
+	}
+	if len(segments) == 0 {
+		return resolved, nil
+	}
+	return path.Join(resolved, segments...)
+}
@@ -0,0 +1,253 @@
+package node
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/boxo/namesys"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/go-cid"
+	ci "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type countingNamesys struct {
+	values map[string]namesys.Result
+	calls  int
+}
+
+func (m *countingNamesys) Resolve(ctx context.Context, p path.Path, opts ...namesys.ResolveOption) (namesys.Result, error) {
+	m.calls++
+	res, ok := m.values[p.String()]
+	if !ok {
+		return namesys.Result{}, namesys.ErrResolveFailed
+	}
+	return res, nil
+}
+
+func (m *countingNamesys) ResolveAsync(ctx context.Context, p path.Path, opts ...namesys.ResolveOption) <-chan namesys.AsyncResult {
+	out := make(chan namesys.AsyncResult, 1)
+	res, err := m.Resolve(ctx, p, opts...)
+	out <- namesys.AsyncResult{Path: res.Path, TTL: res.TTL, LastMod: res.LastMod, Err: err}
+	close(out)
+	return out
+}
+
+func (m *countingNamesys) Publish(ctx context.Context, name ci.PrivKey, value path.Path, opts ...namesys.PublishOption) error {
+	pid, err := peer.IDFromPrivateKey(name)
+	if err != nil {
+		return err
+	}
+	m.values[ipns.NameFromPeer(pid).AsPath().String()] = namesys.Result{Path: value, TTL: time.Hour}
+	return nil
+}
+
+func testImmutablePath(t *testing.T, data string) path.Path {
+	h, err := mh.Sum([]byte(data), mh.SHA2_256, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path.FromCid(cid.NewCidV1(cid.Raw, h))
+}
+
+func testMutablePath(t *testing.T, name string) path.Path {
+	p, err := path.NewPath("/ipns/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestCachedNameSystem(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("hits, misses and evictions", func(t *testing.T) {
+		mock := &countingNamesys{values: map[string]namesys.Result{
+			"/ipns/a.example.com": {Path: testImmutablePath(t, "a"), TTL: time.Hour},
+			"/ipns/b.example.com": {Path: testImmutablePath(t, "b"), TTL: time.Hour},
+		}}
+		ns, err := NewCachedNameSystem(mock, 1, 0, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		a := testMutablePath(t, "a.example.com")
+		b := testMutablePath(t, "b.example.com")
+		hits := testutil.ToFloat64(ipnsCacheHitsMetric)
+		misses := testutil.ToFloat64(ipnsCacheMissesMetric)
+		evictions := testutil.ToFloat64(ipnsCacheEvictionsMetric)
+		for _, p := range []path.Path{a, a, b, a} {
+			if _, err := ns.Resolve(ctx, p); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		stats := ns.CacheStats()
+		if stats.Hits != 1 || stats.Misses != 3 || stats.Evictions != 2 {
+			t.Fatalf("unexpected stats: %+v", stats)
+		}
+		if testutil.ToFloat64(ipnsCacheHitsMetric)-hits != 1 ||
+			testutil.ToFloat64(ipnsCacheMissesMetric)-misses != 3 ||
+			testutil.ToFloat64(ipnsCacheEvictionsMetric)-evictions != 2 {
+			t.Fatal("expected the stats to be exported to Prometheus")
+		}
+		if mock.calls != 3 {
+			t.Fatalf("expected 3 calls to the wrapped name system, got %d", mock.calls)
+		}
+	})
+
+	t.Run("sub paths share the cache entry", func(t *testing.T) {
+		mock := &countingNamesys{values: map[string]namesys.Result{
+			"/ipns/a.example.com": {Path: testImmutablePath(t, "a"), TTL: time.Hour},
+		}}
+		ns, err := NewCachedNameSystem(mock, 8, 0, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := ns.Resolve(ctx, testMutablePath(t, "a.example.com")); err != nil {
+			t.Fatal(err)
+		}
+		res, err := ns.Resolve(ctx, testMutablePath(t, "a.example.com/sub/file"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := testImmutablePath(t, "a").String() + "/sub/file"
+		if res.Path.String() != expected {
+			t.Fatalf("expected %s, got %s", expected, res.Path)
+		}
+		if mock.calls != 1 {
+			t.Fatalf("expected 1 call to the wrapped name system, got %d", mock.calls)
+		}
+	})
+
+	t.Run("min cache ttl keeps short-lived entries", func(t *testing.T) {
+		mock := &countingNamesys{values: map[string]namesys.Result{
+			"/ipns/a.example.com": {Path: testImmutablePath(t, "a"), TTL: time.Nanosecond},
+		}}
+		ns, err := NewCachedNameSystem(mock, 8, time.Hour, time.Hour*2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		a := testMutablePath(t, "a.example.com")
+		for i := 0; i < 3; i++ {
+			if _, err := ns.Resolve(ctx, a); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if mock.calls != 1 {
+			t.Fatalf("expected 1 call to the wrapped name system, got %d", mock.calls)
+		}
+	})
+
+	t.Run("min cache ttl entries are bounded by the cache size", func(t *testing.T) {
+		mock := &countingNamesys{values: map[string]namesys.Result{
+			"/ipns/a.example.com": {Path: testImmutablePath(t, "a"), TTL: time.Nanosecond},
+			"/ipns/b.example.com": {Path: testImmutablePath(t, "b"), TTL: time.Nanosecond},
+		}}
+		ns, err := NewCachedNameSystem(mock, 1, time.Hour, time.Hour*2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		a := testMutablePath(t, "a.example.com")
+		b := testMutablePath(t, "b.example.com")
+		for _, p := range []path.Path{a, b, a} {
+			if _, err := ns.Resolve(ctx, p); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if mock.calls != 3 {
+			t.Fatalf("expected 3 calls to the wrapped name system, got %d", mock.calls)
+		}
+		if stats := ns.CacheStats(); stats.Evictions != 2 {
+			t.Fatalf("expected 2 evictions, got %+v", stats)
+		}
+	})
+
+	t.Run("min cache ttl applies to async resolution", func(t *testing.T) {
+		mock := &countingNamesys{values: map[string]namesys.Result{
+			"/ipns/a.example.com": {Path: testImmutablePath(t, "a"), TTL: time.Nanosecond},
+		}}
+		ns, err := NewCachedNameSystem(mock, 8, time.Hour, time.Hour*2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		a := testMutablePath(t, "a.example.com")
+		for i := 0; i < 3; i++ {
+			for res := range ns.ResolveAsync(ctx, a) {
+				if res.Err != nil {
+					t.Fatal(res.Err)
+				}
+			}
+		}
+		if mock.calls != 1 {
+			t.Fatalf("expected 1 call to the wrapped name system, got %d", mock.calls)
+		}
+	})
+
+	t.Run("all spellings of a name share the cache entry", func(t *testing.T) {
+		mock := &countingNamesys{values: map[string]namesys.Result{
+			"/ipns/a.example.com": {Path: testImmutablePath(t, "a"), TTL: time.Hour},
+		}}
+		ns, err := NewCachedNameSystem(mock, 8, 0, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sk, _, err := ci.GenerateEd25519Key(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pid, err := peer.IDFromPrivateKey(sk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		value := testImmutablePath(t, "published")
+		if err := ns.Publish(ctx, sk, value); err != nil {
+			t.Fatal(err)
+		}
+
+		// The peer ID and CIDv1 forms of the published name, and a DNSLink
+		// name with another casing.
+		v1 := peer.ToCid(pid).String()
+		for _, name := range []string{pid.String(), v1, strings.ToUpper(v1), "a.example.com", "A.Example.com"} {
+			if _, err := ns.Resolve(ctx, testMutablePath(t, name)); err != nil {
+				t.Fatal(name, err)
+			}
+		}
+		if mock.calls != 1 {
+			t.Fatalf("expected 1 call to the wrapped name system, got %d", mock.calls)
+		}
+		if stats := ns.CacheStats(); stats.Hits != 4 || stats.Misses != 1 {
+			t.Fatalf("unexpected stats: %+v", stats)
+		}
+	})
+
+	t.Run("max cache ttl of zero disables caching", func(t *testing.T) {
+		mock := &countingNamesys{values: map[string]namesys.Result{
+			"/ipns/a.example.com": {Path: testImmutablePath(t, "a"), TTL: time.Hour},
+		}}
+		ns, err := NewCachedNameSystem(mock, 8, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		a := testMutablePath(t, "a.example.com")
+		for i := 0; i < 3; i++ {
+			if _, err := ns.Resolve(ctx, a); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if mock.calls != 3 {
+			t.Fatalf("expected 3 calls to the wrapped name system, got %d", mock.calls)
+		}
+	})
+}
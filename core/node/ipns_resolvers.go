@@ -0,0 +1,116 @@
+package node
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/boxo/namesys"
+	"github.com/ipfs/boxo/path"
+	drclient "github.com/ipfs/boxo/routing/http/client"
+	"github.com/ipfs/boxo/routing/http/contentrouter"
+	version "github.com/ipfs/kubo"
+	"github.com/ipfs/kubo/config"
+)
+
+// trustedResolverNameSystem resolves IPNS names through the delegated routing
+// endpoints configured in Ipns.Resolvers, falling back to the wrapped name
+// system when no endpoint matches or the endpoint fails.
+type trustedResolverNameSystem struct {
+	namesys.NameSystem
+
+	cfg       *config.Ipns
+	resolvers map[string]namesys.Resolver
+}
+
+// WithTrustedResolvers wraps ns so that the resolvers in cfg.Resolvers are
+// consulted first. ns is returned unchanged when none are configured.
+func WithTrustedResolvers(ns namesys.NameSystem, cfg *config.Ipns) (namesys.NameSystem, error) {
+	if cfg == nil || len(cfg.Resolvers) == 0 {
+		return ns, nil
+	}
+
+	resolvers := make(map[string]namesys.Resolver, len(cfg.Resolvers))
+	for _, endpoint := range cfg.Resolvers {
+		if _, ok := resolvers[endpoint]; ok {
+			continue
+		}
+		cli, err := drclient.New(endpoint, drclient.WithUserAgent(version.GetUserAgentVersion()))
+		if err != nil {
+			return nil, fmt.Errorf("creating IPNS resolver for %q: %w", endpoint, err)
+		}
+		resolvers[endpoint] = namesys.NewIPNSResolver(WithLifetimeCap(contentrouter.NewContentRoutingClient(cli), cfg.AcceptedLifetime()))
+	}
+
+	return &trustedResolverNameSystem{
+		NameSystem: ns,
+		cfg:        cfg,
+		resolvers:  resolvers,
+	}, nil
+}
+
+func (ns *trustedResolverNameSystem) Resolve(ctx context.Context, p path.Path, options ...namesys.ResolveOption) (namesys.Result, error) {
+	if res, ok := ns.resolveTrusted(ctx, p, options...); ok {
+		return res, nil
+	}
+	return ns.NameSystem.Resolve(ctx, p, options...)
+}
+
+func (ns *trustedResolverNameSystem) ResolveAsync(ctx context.Context, p path.Path, options ...namesys.ResolveOption) <-chan namesys.AsyncResult {
+	out := make(chan namesys.AsyncResult)
+	go func() {
+		defer close(out)
+
+		if res, ok := ns.resolveTrusted(ctx, p, options...); ok {
+			select {
+			case out <- namesys.AsyncResult{Path: res.Path, TTL: res.TTL, LastMod: res.LastMod}:
+			case <-ctx.Done():
+			}
+			return
+		}
+
+		for res := range ns.NameSystem.ResolveAsync(ctx, p, options...) {
+			select {
+			case out <- res:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// resolveTrusted resolves p with the trusted resolver matching its IPNS
+// name. It reports false when p is not an IPNS name, no resolver matches, or
+// the resolver fails, in which case the caller falls back to the regular
+// name system.
+func (ns *trustedResolverNameSystem) resolveTrusted(ctx context.Context, p path.Path, options ...namesys.ResolveOption) (namesys.Result, bool) {
+	if p.Namespace() != path.IPNSNamespace {
+		return namesys.Result{}, false
+	}
+	name, err := ipns.NameFromString(p.Segments()[1])
+	if err != nil {
+		// DNSLink names are resolved over DNS, see DNS.Resolvers.
+		return namesys.Result{}, false
+	}
+	endpoint, ok := ns.cfg.ResolverFor(name.String())
+	if !ok {
+		return namesys.Result{}, false
+	}
+
+	res, err := ns.resolvers[endpoint].Resolve(ctx, p, options...)
+	if err != nil {
+		logger.Debugf("trusted IPNS resolver %s failed for %s, falling back: %s", endpoint, name, err)
+		return namesys.Result{}, false
+	}
+
+	// The record may point at another name; finish resolving it through
+	// the regular name system.
+	if res.Path.Namespace() == path.IPNSNamespace {
+		res, err = ns.NameSystem.Resolve(ctx, res.Path, options...)
+		if err != nil {
+			return namesys.Result{}, false
+		}
+	}
+	return res, true
+}
@@ -0,0 +1,158 @@
+package node
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/boxo/namesys"
+	"github.com/ipfs/boxo/path"
+	"github.com/ipfs/kubo/config"
+	ci "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func newTestName(t *testing.T) (ci.PrivKey, ipns.Name) {
+	sk, _, err := ci.GenerateEd25519Key(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sk, ipns.NameFromPeer(pid)
+}
+
+func TestTrustedResolvers(t *testing.T) {
+	trustedKey, trustedName := newTestName(t)
+	_, otherName := newTestName(t)
+
+	trustedValue, err := path.NewPath("/ipfs/bafkqaaa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := ipns.NewRecord(trustedKey, trustedValue, 1, time.Now().Add(time.Hour), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ipns.MarshalRecord(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/routing/v1/ipns/"+trustedName.String()) {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.ipfs.ipns-record")
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	fallbackValue, err := path.NewPath("/ipfs/bafkreifjjcie6lypi6ny7amxnfftagclbuxndqonfipmb64f2km2devei4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapped := &countingNamesys{values: map[string]namesys.Result{
+		trustedName.AsPath().String(): {Path: fallbackValue},
+		otherName.AsPath().String():   {Path: fallbackValue},
+	}}
+	ns, err := WithTrustedResolvers(wrapped, &config.Ipns{Resolvers: map[string]string{".": srv.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := ns.Resolve(context.Background(), trustedName.AsPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Path.String() != trustedValue.String() {
+		t.Fatalf("expected %s from the trusted resolver, got %s", trustedValue, res.Path)
+	}
+	if wrapped.calls != 0 {
+		t.Fatalf("expected no fallback, got %d calls", wrapped.calls)
+	}
+
+	// The trusted resolver has no record for otherName.
+	res, err = ns.Resolve(context.Background(), otherName.AsPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Path.String() != fallbackValue.String() {
+		t.Fatalf("expected fallback value %s, got %s", fallbackValue, res.Path)
+	}
+	if wrapped.calls != 1 {
+		t.Fatalf("expected one fallback call, got %d", wrapped.calls)
+	}
+
+	// Keys configured as peer IDs match the canonical name, and other names
+	// are not sent to the trusted resolver without a "." fallback.
+	ns, err = WithTrustedResolvers(wrapped, &config.Ipns{Resolvers: map[string]string{trustedName.Peer().String(): srv.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err = ns.Resolve(context.Background(), trustedName.AsPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Path.String() != trustedValue.String() {
+		t.Fatalf("expected %s from the trusted resolver, got %s", trustedValue, res.Path)
+	}
+	res, err = ns.Resolve(context.Background(), otherName.AsPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Path.String() != fallbackValue.String() {
+		t.Fatalf("expected fallback value %s, got %s", fallbackValue, res.Path)
+	}
+	if wrapped.calls != 2 {
+		t.Fatalf("expected two fallback calls, got %d", wrapped.calls)
+	}
+}
+
+func TestTrustedResolversAsync(t *testing.T) {
+	_, name := newTestName(t)
+
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	fallbackValue, err := path.NewPath("/ipfs/bafkqaaa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapped := &countingNamesys{values: map[string]namesys.Result{
+		name.AsPath().String(): {Path: fallbackValue},
+	}}
+	ns, err := WithTrustedResolvers(wrapped, &config.Ipns{Resolvers: map[string]string{".": srv.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The channel is returned while the trusted resolver is still
+	// answering, and the fallback result arrives once it has failed.
+	resCh := ns.ResolveAsync(context.Background(), name.AsPath())
+	select {
+	case res := <-resCh:
+		t.Fatalf("unexpected result before the trusted resolver answered: %+v", res)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+
+	var results []namesys.AsyncResult
+	for res := range resCh {
+		results = append(results, res)
+	}
+	if len(results) != 1 || results[0].Err != nil || results[0].Path.String() != fallbackValue.String() {
+		t.Fatalf("expected the fallback value %s, got %+v", fallbackValue, results)
+	}
+}
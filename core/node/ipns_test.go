@@ -0,0 +1,120 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ipfs/boxo/ipns"
+	"github.com/ipfs/boxo/namesys"
+	"github.com/ipfs/boxo/path"
+	offlineroute "github.com/ipfs/boxo/routing/offline"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/ipfs/kubo/config"
+	record "github.com/libp2p/go-libp2p-record"
+	ci "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+)
+
+func TestLifetimeCap(t *testing.T) {
+	ctx := context.Background()
+	rt := offlineroute.NewOfflineRouter(dssync.MutexWrap(ds.NewMapDatastore()), record.NamespacedValidator{
+		"ipns": ipns.Validator{},
+	})
+
+	if vs := WithLifetimeCap(rt, config.DefaultIpnsMaxAcceptedLifetime); vs != routing.ValueStore(rt) {
+		t.Fatal("expected the value store to be returned unchanged without a cap")
+	}
+
+	putRecord := func(t *testing.T, lifetime time.Duration) ipns.Name {
+		sk, _, err := ci.GenerateEd25519Key(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pid, err := peer.IDFromPrivateKey(sk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec, err := ipns.NewRecord(sk, testImmutablePath(t, "value"), 1, time.Now().Add(lifetime), time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		val, err := ipns.MarshalRecord(rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := ipns.NameFromPeer(pid)
+		if err := rt.PutValue(ctx, string(name.RoutingKey()), val); err != nil {
+			t.Fatal(err)
+		}
+		return name
+	}
+
+	vs := WithLifetimeCap(rt, 2*time.Hour)
+	ns, err := namesys.NewNameSystem(vs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("record within the cap", func(t *testing.T) {
+		name := putRecord(t, time.Hour)
+		if _, err := vs.GetValue(ctx, string(name.RoutingKey())); err != nil {
+			t.Fatal(err)
+		}
+		res, err := ns.Resolve(ctx, name.AsPath())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Path.String() != testImmutablePath(t, "value").String() {
+			t.Fatalf("unexpected path %s", res.Path)
+		}
+	})
+
+	t.Run("record past the cap", func(t *testing.T) {
+		name := putRecord(t, 365*24*time.Hour)
+		if _, err := vs.GetValue(ctx, string(name.RoutingKey())); !errors.Is(err, errLifetimeExceeded) {
+			t.Fatalf("expected %q, got %v", errLifetimeExceeded, err)
+		}
+		if _, err := ns.Resolve(ctx, name.AsPath()); err == nil {
+			t.Fatal("expected the record to be treated as expired")
+		}
+		// The record itself is still stored and served as is.
+		if _, err := rt.GetValue(ctx, string(name.RoutingKey())); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestNamesysDisabled(t *testing.T) {
+	ns, err := Namesys(false, DefaultIpnsCacheSize, 0, 0, nil)(nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := path.NewPath("/ipns/example.net")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ns.Resolve(context.Background(), p); !errors.Is(err, ErrIpnsDisabled) {
+		t.Fatalf("expected %q, got %v", ErrIpnsDisabled, err)
+	}
+	res := <-ns.ResolveAsync(context.Background(), p)
+	if !errors.Is(res.Err, ErrIpnsDisabled) {
+		t.Fatalf("expected %q, got %v", ErrIpnsDisabled, res.Err)
+	}
+
+	sk, _, err := ci.GenerateEd25519Key(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := path.NewPath("/ipfs/bafkqaaa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ns.Publish(context.Background(), sk, value); !errors.Is(err, ErrIpnsDisabled) {
+		t.Fatalf("expected %q, got %v", ErrIpnsDisabled, err)
+	}
+}
@@ -48,6 +48,17 @@ func toRawCids(set *cid.Set) (*cid.Set, error) {
 // The routine then iterates over every block in the blockstore and
 // deletes any block that is not found in the marked set.
 func GC(ctx context.Context, bs bstore.GCBlockstore, dstor dstore.Datastore, pn pin.Pinner, bestEffortRoots []cid.Cid) <-chan Result {
+	return collect(ctx, bs, dstor, pn, bestEffortRoots, false)
+}
+
+// Unreachable streams the blocks that GC would remove, in the KeyRemoved
+// field of the results, without removing them. It holds the GC lock while
+// running, like GC, so pins can't change until it is done.
+func Unreachable(ctx context.Context, bs bstore.GCBlockstore, pn pin.Pinner, bestEffortRoots []cid.Cid) <-chan Result {
+	return collect(ctx, bs, nil, pn, bestEffortRoots, true)
+}
+
+func collect(ctx context.Context, bs bstore.GCBlockstore, dstor dstore.Datastore, pn pin.Pinner, bestEffortRoots []cid.Cid, dryRun bool) <-chan Result {
 	ctx, cancel := context.WithCancel(ctx)
 
 	unlocker := bs.GCLock(ctx)
@@ -103,7 +114,10 @@ func GC(ctx context.Context, bs bstore.GCBlockstore, dstor dstore.Datastore, pn
 				// NOTE: assumes that all CIDs returned by the keychan are _raw_ CIDv1 CIDs.
 				// This means we keep the block as long as we want it somewhere (CIDv1, CIDv0, Raw, other...).
 				if !gcs.Has(k) {
-					err := bs.DeleteBlock(ctx, k)
+					var err error
+					if !dryRun {
+						err = bs.DeleteBlock(ctx, k)
+					}
 					removed++
 					if err != nil {
 						errors = true
@@ -133,6 +147,10 @@ func GC(ctx context.Context, bs bstore.GCBlockstore, dstor dstore.Datastore, pn
 			}
 		}
 
+		if dryRun {
+			return
+		}
+
 		gds, ok := dstor.(dstore.GCDatastore)
 		if !ok {
 			return
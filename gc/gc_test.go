@@ -87,6 +87,59 @@ func TestGC(t *testing.T) {
 	require.ElementsMatch(t, expectedKept, kept)
 }
 
+func TestUnreachable(t *testing.T) {
+	ctx := context.Background()
+
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	bs := blockstore.NewGCBlockstore(blockstore.NewBlockstore(ds), blockstore.NewGCLocker())
+	bserv := blockservice.New(bs, offline.Exchange(bs))
+	dserv := merkledag.NewDAGService(bserv)
+	pinner, err := dspinner.New(ctx, ds, dserv)
+	require.NoError(t, err)
+
+	daggen := mdutils.NewDAGGenerator()
+
+	var expectedAll []multihash.Multihash
+	var expectedUnreachable []multihash.Multihash
+
+	root, allCids, err := daggen.MakeDagNode(dserv.Add, 5, 2)
+	require.NoError(t, err)
+	err = pinner.PinWithMode(ctx, root, pin.Recursive, "")
+	require.NoError(t, err)
+	expectedAll = append(expectedAll, toMHs(allCids)...)
+
+	err = pinner.Flush(ctx)
+	require.NoError(t, err)
+
+	bestEffortRoot, allCids, err := daggen.MakeDagNode(dserv.Add, 5, 2)
+	require.NoError(t, err)
+	expectedAll = append(expectedAll, toMHs(allCids)...)
+
+	for i := 0; i < 5; i++ {
+		_, allCids, err := daggen.MakeDagNode(dserv.Add, 5, 2)
+		require.NoError(t, err)
+		expectedUnreachable = append(expectedUnreachable, toMHs(allCids)...)
+	}
+	expectedAll = append(expectedAll, expectedUnreachable...)
+
+	ch := Unreachable(ctx, bs, pinner, []cid.Cid{bestEffortRoot})
+	var unreachable []multihash.Multihash
+	for res := range ch {
+		require.NoError(t, res.Error)
+		unreachable = append(unreachable, res.KeyRemoved.Hash())
+	}
+	require.ElementsMatch(t, expectedUnreachable, unreachable)
+
+	// Nothing is removed.
+	allKeys, err := bs.AllKeysChan(ctx)
+	require.NoError(t, err)
+	var all []multihash.Multihash
+	for key := range allKeys {
+		all = append(all, key.Hash())
+	}
+	require.ElementsMatch(t, expectedAll, all)
+}
+
 func toMHs(cids []cid.Cid) []multihash.Multihash {
 	res := make([]multihash.Multihash, len(cids))
 	for i, c := range cids {
@@ -0,0 +1,91 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	gostream "github.com/libp2p/go-libp2p-gostream"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// Dial opens a stream to proto on peer and returns it as a net.Conn, for Go
+// code that wants to use a libp2p service without going through a local
+// listener and ForwardLocal.
+func (p2p *P2P) Dial(ctx context.Context, peer peer.ID, proto protocol.ID) (net.Conn, error) {
+	return gostream.Dial(ctx, p2p.peerHost, peer, proto)
+}
+
+// Listen handles proto and returns the incoming streams as net.Conns. The
+// listener is registered in ListenersP2P, so it is listed by 'ipfs p2p ls'
+// and can be closed by 'ipfs p2p close'. It is also closed when ctx is done.
+//
+// ErrProtocolRegistered is returned when proto is already handled by a p2p
+// listener or by the node itself.
+func (p2p *P2P) Listen(ctx context.Context, proto protocol.ID) (net.Listener, error) {
+	// Hold the registry lock from the check to the registration, so that
+	// concurrent calls can't both take proto.
+	p2p.ListenersP2P.Lock()
+	defer p2p.ListenersP2P.Unlock()
+
+	if _, ok := p2p.ListenersP2P.Listeners[proto]; ok || p2p.CheckProtoExists(proto) {
+		return nil, fmt.Errorf("%w: %s", ErrProtocolRegistered, proto)
+	}
+
+	l, err := gostream.Listen(p2p.peerHost, proto)
+	if err != nil {
+		return nil, err
+	}
+	listener := &streamListener{Listener: l, p2p: p2p, proto: proto}
+	p2p.ListenersP2P.Listeners[listener.key()] = listener
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+	return listener, nil
+}
+
+// streamListener is the Listener registered for P2P.Listen. Its connections
+// are handled in process by the caller of Listen, so its target is the node
+// itself.
+type streamListener struct {
+	net.Listener
+
+	p2p   *P2P
+	proto protocol.ID
+}
+
+// Close removes the listener from ListenersP2P and stops it.
+func (l *streamListener) Close() error {
+	l.p2p.ListenersP2P.Close(func(other Listener) bool {
+		return other == l
+	})
+	return nil
+}
+
+func (l *streamListener) close() {
+	l.Listener.Close()
+}
+
+func (l *streamListener) Protocol() protocol.ID {
+	return l.proto
+}
+
+func (l *streamListener) ListenAddress() ma.Multiaddr {
+	addr, err := ma.NewMultiaddr(maPrefix + l.p2p.identity.String())
+	if err != nil {
+		panic(err)
+	}
+	return addr
+}
+
+func (l *streamListener) TargetAddress() ma.Multiaddr {
+	return l.ListenAddress()
+}
+
+func (l *streamListener) key() protocol.ID {
+	return l.proto
+}
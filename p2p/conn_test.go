@@ -0,0 +1,153 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+func TestDialListen(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn, err := mocknet.FullMeshConnected(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts := mn.Hosts()
+	client := New(hosts[0].ID(), hosts[0], hosts[0].Peerstore())
+	server := New(hosts[1].ID(), hosts[1], hosts[1].Peerstore())
+
+	l, err := server.Listen(ctx, "/x/echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		_, _ = io.Copy(c, c)
+	}()
+
+	c, err := client.Dial(ctx, hosts[1].ID(), "/x/echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(c, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "hello" {
+		t.Fatalf("expected hello, got %q", buf)
+	}
+
+	// The protocol is taken for p2p listeners, and the other way around.
+	if _, err := server.ForwardRemote(ctx, "/x/echo", ma.StringCast("/ip4/127.0.0.1/tcp/10101"), false); !errors.Is(err, ErrProtocolRegistered) {
+		t.Fatalf("expected %q, got %v", ErrProtocolRegistered, err)
+	}
+	if _, err := server.ForwardRemote(ctx, "/x/other", ma.StringCast("/ip4/127.0.0.1/tcp/10101"), false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.Listen(ctx, "/x/other"); !errors.Is(err, ErrProtocolRegistered) {
+		t.Fatalf("expected %q, got %v", ErrProtocolRegistered, err)
+	}
+
+	// The listener shows up next to the other p2p listeners.
+	server.ListenersP2P.RLock()
+	registered, ok := server.ListenersP2P.Listeners["/x/echo"]
+	server.ListenersP2P.RUnlock()
+	if !ok || registered.(net.Listener) != l {
+		t.Fatalf("expected the listener to be registered, got %v", registered)
+	}
+
+	cancel()
+	if _, err := l.Accept(); err == nil {
+		t.Fatal("expected the listener to be closed with its context")
+	}
+	server.ListenersP2P.RLock()
+	_, ok = server.ListenersP2P.Listeners["/x/echo"]
+	server.ListenersP2P.RUnlock()
+	if ok {
+		t.Fatal("expected the listener to be removed once closed")
+	}
+}
+
+func TestListenRegistry(t *testing.T) {
+	ctx := context.Background()
+
+	mn, err := mocknet.FullMeshConnected(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts := mn.Hosts()
+	client := New(hosts[0].ID(), hosts[0], hosts[0].Peerstore())
+	server := New(hosts[1].ID(), hosts[1], hosts[1].Peerstore())
+
+	// Only one of concurrent calls gets the protocol.
+	var wg sync.WaitGroup
+	var won atomic.Int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := server.Listen(ctx, "/x/race")
+			switch {
+			case err == nil:
+				won.Add(1)
+			case !errors.Is(err, ErrProtocolRegistered):
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := won.Load(); n != 1 {
+		t.Fatalf("expected a single listener, got %d", n)
+	}
+
+	// Closing it like 'ipfs p2p close' does stops it and frees the protocol.
+	l := server.ListenersP2P.Listeners["/x/race"].(net.Listener)
+	if n := server.ListenersP2P.Close(func(l Listener) bool { return l.Protocol() == "/x/race" }); n != 1 {
+		t.Fatalf("expected 1 listener to be closed, got %d", n)
+	}
+	if _, err := l.Accept(); err == nil {
+		t.Fatal("expected the listener to be closed")
+	}
+	if _, err := client.Dial(ctx, hosts[1].ID(), "/x/race"); err == nil {
+		t.Fatal("expected the protocol to be gone")
+	}
+
+	l, err = server.Listen(ctx, "/x/race")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		c, err := l.Accept()
+		if err == nil {
+			c.Close()
+		}
+	}()
+	c, err := client.Dial(ctx, hosts[1].ID(), "/x/race")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	// Closing the net.Listener removes it from the registry.
+	l.Close()
+	if n := len(server.ListenersP2P.Listeners); n != 0 {
+		t.Fatalf("expected no listener left, got %d", n)
+	}
+}
@@ -10,6 +10,10 @@ import (
 	ma "github.com/multiformats/go-multiaddr"
 )
 
+// ErrListenerRegistered is returned by Listeners.Register when a listener with
+// the same key (listen address, or protocol for p2p listeners) already exists.
+var ErrListenerRegistered = errors.New("listener already registered")
+
 // Listener listens for connections and proxies them to a target.
 type Listener interface {
 	Protocol() protocol.ID
@@ -45,15 +49,15 @@ func newListenersP2P(host p2phost.Host) *Listeners {
 		reg.RLock()
 		defer reg.RUnlock()
 
-		_, ok := reg.Listeners[p]
+		// Listeners from P2P.Listen have a stream handler of their own.
+		_, ok := reg.Listeners[p].(*remoteListener)
 		return ok
 	}, func(stream net.Stream) {
 		reg.RLock()
 		defer reg.RUnlock()
 
-		l := reg.Listeners[stream.Protocol()]
-		if l != nil {
-			go l.(*remoteListener).handleStream(stream)
+		if l, ok := reg.Listeners[stream.Protocol()].(*remoteListener); ok {
+			go l.handleStream(stream)
 		}
 	})
 
@@ -66,7 +70,7 @@ func (r *Listeners) Register(l Listener) error {
 	defer r.Unlock()
 
 	if _, ok := r.Listeners[l.key()]; ok {
-		return errors.New("listener already registered")
+		return ErrListenerRegistered
 	}
 
 	r.Listeners[l.key()] = l
@@ -2,6 +2,7 @@ package p2p
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	tec "github.com/jbenet/go-temp-err-catcher"
@@ -22,16 +23,33 @@ type localListener struct {
 	laddr ma.Multiaddr
 	peer  peer.ID
 
+	// idleTimeout is applied to every stream accepted by this listener.
+	idleTimeout time.Duration
+	retry       DialRetry
+
 	listener manet.Listener
 }
 
+// DialRetry configures how opening a stream to the remote peer of a local
+// listener is retried. The zero value does not retry.
+type DialRetry struct {
+	// Retries is the number of attempts made after the first one failed.
+	Retries int
+	// Delay is the wait before the first retry, it doubles after every
+	// failed retry.
+	Delay time.Duration
+}
+
 // ForwardLocal creates new P2P stream to a remote listener.
-func (p2p *P2P) ForwardLocal(ctx context.Context, peer peer.ID, proto protocol.ID, bindAddr ma.Multiaddr) (Listener, error) {
+func (p2p *P2P) ForwardLocal(ctx context.Context, peer peer.ID, proto protocol.ID, bindAddr ma.Multiaddr, opts ...ForwardOption) (Listener, error) {
+	o := processForwardOptions(forwardOptions{}, opts)
 	listener := &localListener{
-		ctx:   ctx,
-		p2p:   p2p,
-		proto: proto,
-		peer:  peer,
+		ctx:         ctx,
+		p2p:         p2p,
+		proto:       proto,
+		peer:        peer,
+		idleTimeout: o.idleTimeout,
+		retry:       o.retry,
 	}
 
 	maListener, err := manet.Listen(bindAddr)
@@ -52,10 +70,35 @@ func (p2p *P2P) ForwardLocal(ctx context.Context, peer peer.ID, proto protocol.I
 }
 
 func (l *localListener) dial(ctx context.Context) (net.Stream, error) {
-	cctx, cancel := context.WithTimeout(ctx, time.Second*30) // TODO: configurable?
-	defer cancel()
+	return l.p2p.newStream(ctx, l.peer, l.proto, l.retry)
+}
 
-	return l.p2p.peerHost.NewStream(cctx, l.peer, l.proto)
+// newStream opens a stream to proto on peer, which looks the peer up if
+// needed, retrying failures as configured by retry.
+func (p2p *P2P) newStream(ctx context.Context, peer peer.ID, proto protocol.ID, retry DialRetry) (net.Stream, error) {
+	delay := retry.Delay
+	for attempt := 1; ; attempt++ {
+		cctx, cancel := context.WithTimeout(ctx, time.Second*30) // TODO: configurable?
+		s, err := p2p.peerHost.NewStream(cctx, peer, proto)
+		cancel()
+		if err == nil {
+			return s, nil
+		}
+		if attempt > retry.Retries {
+			if attempt > 1 {
+				err = fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+			}
+			return nil, err
+		}
+
+		log.Debugf("failed to dial to remote %s/%s, retrying in %s: %s", peer, proto, delay, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("giving up after %d attempts: %w", attempt, ctx.Err())
+		}
+		delay *= 2
+	}
 }
 
 func (l *localListener) acceptConns() {
@@ -76,7 +119,7 @@ func (l *localListener) setupStream(local manet.Conn) {
 	remote, err := l.dial(l.ctx)
 	if err != nil {
 		local.Close()
-		log.Warnf("failed to dial to remote %s/%s", l.peer, l.proto)
+		log.Warnf("failed to dial to remote %s/%s: %s", l.peer, l.proto, err)
 		return
 	}
 
@@ -91,6 +134,8 @@ func (l *localListener) setupStream(local manet.Conn) {
 		Remote: remote,
 
 		Registry: l.p2p.Streams,
+
+		idleTimeout: l.idleTimeout,
 	}
 
 	l.p2p.Streams.Register(stream)
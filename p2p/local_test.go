@@ -0,0 +1,38 @@
+package p2p
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+func TestNewStreamRetry(t *testing.T) {
+	ctx := context.Background()
+
+	mn, err := mocknet.FullMeshConnected(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts := mn.Hosts()
+	client, server := hosts[0], hosts[1]
+	p := New(client.ID(), client, client.Peerstore())
+
+	_, err = p.newStream(ctx, server.ID(), "/x/test", DialRetry{Retries: 2, Delay: time.Millisecond})
+	if err == nil || !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Fatalf("expected an error after 3 attempts, got %v", err)
+	}
+
+	// The handler shows up while retrying.
+	time.AfterFunc(50*time.Millisecond, func() {
+		server.SetStreamHandler("/x/test", func(s network.Stream) { s.Close() })
+	})
+	s, err := p.newStream(ctx, server.ID(), "/x/test", DialRetry{Retries: 10, Delay: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+}
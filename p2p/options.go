@@ -0,0 +1,62 @@
+package p2p
+
+import (
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// ForwardOption configures the listeners created by ForwardLocal,
+// ForwardRemote and their UDP variants.
+type ForwardOption func(*forwardOptions)
+
+type forwardOptions struct {
+	idleTimeout time.Duration
+	retry       DialRetry
+	allowed     []peer.ID
+	maxSessions int
+}
+
+// processForwardOptions applies opts on top of defaults.
+func processForwardOptions(defaults forwardOptions, opts []ForwardOption) forwardOptions {
+	o := defaults
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithIdleTimeout resets forwarded streams once no data was sent in either
+// direction for d. Zero disables the timeout, which is the default except
+// for UDP tunnels, see DefaultUDPIdleTimeout.
+func WithIdleTimeout(d time.Duration) ForwardOption {
+	return func(o *forwardOptions) {
+		o.idleTimeout = d
+	}
+}
+
+// WithDialRetry sets how failures to reach the remote listener are retried by
+// local listeners. By default, failures are not retried.
+func WithDialRetry(retry DialRetry) ForwardOption {
+	return func(o *forwardOptions) {
+		o.retry = retry
+	}
+}
+
+// WithAllowedPeers makes remote listeners reset the streams opened by peers
+// other than the given ones. By default, all peers are allowed.
+func WithAllowedPeers(peers ...peer.ID) ForwardOption {
+	return func(o *forwardOptions) {
+		o.allowed = append(o.allowed, peers...)
+	}
+}
+
+// WithMaxUDPSessions sets how many local UDP peers a ForwardLocalUDP listener
+// keeps a stream open for. When a new peer shows up while there are n
+// sessions already, the least recently active one is closed. Zero disables
+// the limit. Defaults to DefaultMaxUDPSessions.
+func WithMaxUDPSessions(n int) ForwardOption {
+	return func(o *forwardOptions) {
+		o.maxSessions = n
+	}
+}
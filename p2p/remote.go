@@ -2,9 +2,12 @@ package p2p
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	net "github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/protocol"
 	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr/net"
@@ -12,6 +15,10 @@ import (
 
 var maPrefix = "/" + ma.ProtocolWithCode(ma.P_IPFS).Name + "/"
 
+// ErrProtocolRegistered is returned by ForwardRemote when the protocol is
+// already handled, by another p2p listener or by the node itself.
+var ErrProtocolRegistered = errors.New("protocol already registered")
+
 // remoteListener accepts libp2p streams and proxies them to a manet host.
 type remoteListener struct {
 	p2p *P2P
@@ -25,10 +32,41 @@ type remoteListener struct {
 	// reportRemote if set to true makes the handler send '<base58 remote peerid>\n'
 	// to target before any data is forwarded
 	reportRemote bool
+
+	// idleTimeout is applied to every stream handled by this listener.
+	idleTimeout time.Duration
+
+	// allowed, if not empty, is the set of peers allowed to open streams,
+	// streams from other peers are reset.
+	allowed map[peer.ID]struct{}
+
+	// udp if set to true forwards length-prefixed datagrams read from the
+	// streams to a UDP target, see ForwardLocalUDP.
+	udp bool
 }
 
 // ForwardRemote creates new p2p listener.
-func (p2p *P2P) ForwardRemote(ctx context.Context, proto protocol.ID, addr ma.Multiaddr, reportRemote bool) (Listener, error) {
+func (p2p *P2P) ForwardRemote(ctx context.Context, proto protocol.ID, addr ma.Multiaddr, reportRemote bool, opts ...ForwardOption) (Listener, error) {
+	return p2p.forwardRemote(proto, addr, reportRemote, false, processForwardOptions(forwardOptions{}, opts))
+}
+
+// ForwardRemoteUDP creates new p2p listener forwarding the datagrams tunneled
+// by ForwardLocalUDP to a UDP target. When reportRemote is set, the remote
+// peer ID is sent to the target as the first datagram.
+func (p2p *P2P) ForwardRemoteUDP(ctx context.Context, proto protocol.ID, addr ma.Multiaddr, reportRemote bool, opts ...ForwardOption) (Listener, error) {
+	if !IsUDPAddr(addr) {
+		return nil, fmt.Errorf("%s is not a udp address", addr)
+	}
+	return p2p.forwardRemote(proto, addr, reportRemote, true, processForwardOptions(udpForwardDefaults, opts))
+}
+
+func (p2p *P2P) forwardRemote(proto protocol.ID, addr ma.Multiaddr, reportRemote bool, udp bool, o forwardOptions) (Listener, error) {
+	// Custom protocols may collide with the ones of the node, the p2p
+	// listeners themselves are caught by the registry.
+	if p2p.CheckProtoExists(proto) {
+		return nil, fmt.Errorf("%w: %s", ErrProtocolRegistered, proto)
+	}
+
 	listener := &remoteListener{
 		p2p: p2p,
 
@@ -36,9 +74,21 @@ func (p2p *P2P) ForwardRemote(ctx context.Context, proto protocol.ID, addr ma.Mu
 		addr:  addr,
 
 		reportRemote: reportRemote,
+		idleTimeout:  o.idleTimeout,
+		udp:          udp,
+	}
+
+	if len(o.allowed) > 0 {
+		listener.allowed = make(map[peer.ID]struct{}, len(o.allowed))
+		for _, p := range o.allowed {
+			listener.allowed[p] = struct{}{}
+		}
 	}
 
 	if err := p2p.ListenersP2P.Register(listener); err != nil {
+		if errors.Is(err, ErrListenerRegistered) {
+			return nil, fmt.Errorf("%w: %s", ErrProtocolRegistered, proto)
+		}
 		return nil, err
 	}
 
@@ -46,14 +96,20 @@ func (p2p *P2P) ForwardRemote(ctx context.Context, proto protocol.ID, addr ma.Mu
 }
 
 func (l *remoteListener) handleStream(remote net.Stream) {
+	peer := remote.Conn().RemotePeer()
+
+	if !l.allows(peer) {
+		log.Debugf("rejecting %s stream from %s, peer not allowed", l.proto, peer)
+		_ = remote.Reset()
+		return
+	}
+
 	local, err := manet.Dial(l.addr)
 	if err != nil {
 		_ = remote.Reset()
 		return
 	}
 
-	peer := remote.Conn().RemotePeer()
-
 	if l.reportRemote {
 		if _, err := fmt.Fprintf(local, "%s\n", peer); err != nil {
 			_ = remote.Reset()
@@ -61,6 +117,10 @@ func (l *remoteListener) handleStream(remote net.Stream) {
 		}
 	}
 
+	if l.udp {
+		local = newFramedConn(local)
+	}
+
 	peerMa, err := ma.NewMultiaddr(maPrefix + peer.String())
 	if err != nil {
 		_ = remote.Reset()
@@ -78,11 +138,22 @@ func (l *remoteListener) handleStream(remote net.Stream) {
 		Remote: remote,
 
 		Registry: l.p2p.Streams,
+
+		idleTimeout: l.idleTimeout,
 	}
 
 	l.p2p.Streams.Register(stream)
 }
 
+// allows returns whether p may open streams to this listener.
+func (l *remoteListener) allows(p peer.ID) bool {
+	if len(l.allowed) == 0 {
+		return true
+	}
+	_, ok := l.allowed[p]
+	return ok
+}
+
 func (l *remoteListener) Protocol() protocol.ID {
 	return l.proto
 }
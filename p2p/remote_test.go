@@ -0,0 +1,83 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+func TestForwardRemoteRejectsDuplicateProtocol(t *testing.T) {
+	ctx := context.Background()
+
+	mn, err := mocknet.FullMeshLinked(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := mn.Hosts()[0]
+	p := New(h.ID(), h, h.Peerstore())
+
+	target := ma.StringCast("/ip4/127.0.0.1/tcp/10101")
+	if _, err := p.ForwardRemote(ctx, "/x/test", target, false); err != nil {
+		t.Fatal(err)
+	}
+
+	otherTarget := ma.StringCast("/ip4/127.0.0.1/tcp/10102")
+	if _, err := p.ForwardRemote(ctx, "/x/test", otherTarget, false); !errors.Is(err, ErrProtocolRegistered) {
+		t.Fatalf("expected %q, got %v", ErrProtocolRegistered, err)
+	}
+
+	// Protocols handled by the node itself can't be taken over either.
+	if _, err := p.ForwardRemote(ctx, "/ipfs/id/1.0.0", target, false); !errors.Is(err, ErrProtocolRegistered) {
+		t.Fatalf("expected %q, got %v", ErrProtocolRegistered, err)
+	}
+
+	if len(p.ListenersP2P.Listeners) != 1 {
+		t.Fatalf("expected 1 listener, got %d", len(p.ListenersP2P.Listeners))
+	}
+}
+
+func TestForwardRemoteAllowlist(t *testing.T) {
+	ctx := context.Background()
+
+	mn, err := mocknet.FullMeshLinked(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts := mn.Hosts()
+	server, allowed, denied := hosts[0], hosts[1], hosts[2]
+	p := New(server.ID(), server, server.Peerstore())
+
+	target, err := manet.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+
+	if _, err := p.ForwardRemote(ctx, "/x/test", target.Multiaddr(), false, WithAllowedPeers(allowed.ID())); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := allowed.NewStream(ctx, server.ID(), "/x/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	conn, err := target.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	// The reset may arrive before or after protocol negotiation completes.
+	s, err = denied.NewStream(ctx, server.ID(), "/x/test")
+	if err == nil {
+		_, err = s.Read(make([]byte, 1))
+	}
+	if err == nil {
+		t.Fatal("expected the stream of a peer not allowed to be reset")
+	}
+}
@@ -3,6 +3,8 @@ package p2p
 import (
 	"io"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	ifconnmgr "github.com/libp2p/go-libp2p/core/connmgr"
 	net "github.com/libp2p/go-libp2p/core/network"
@@ -28,6 +30,12 @@ type Stream struct {
 	Remote net.Stream
 
 	Registry *StreamRegistry
+
+	// idleTimeout, if non-zero, resets the stream when no data has been
+	// forwarded in either direction for that long.
+	idleTimeout  time.Duration
+	lastActivity atomic.Int64
+	done         chan struct{}
 }
 
 // close stream endpoints and deregister it.
@@ -42,7 +50,7 @@ func (s *Stream) reset() {
 
 func (s *Stream) startStreaming() {
 	go func() {
-		_, err := io.Copy(s.Local, s.Remote)
+		_, err := io.Copy(s.Local, s.activityReader(s.Remote))
 		if err != nil {
 			s.reset()
 		} else {
@@ -51,13 +59,61 @@ func (s *Stream) startStreaming() {
 	}()
 
 	go func() {
-		_, err := io.Copy(s.Remote, s.Local)
+		_, err := io.Copy(s.Remote, s.activityReader(s.Local))
 		if err != nil {
 			s.reset()
 		} else {
 			s.close()
 		}
 	}()
+
+	if s.idleTimeout > 0 {
+		go s.watchIdle()
+	}
+}
+
+// activityReader returns r, wrapped so that reads are recorded as stream
+// activity when an idle timeout is set.
+func (s *Stream) activityReader(r io.Reader) io.Reader {
+	if s.idleTimeout <= 0 {
+		return r
+	}
+	return &activityReader{r: r, s: s}
+}
+
+// watchIdle resets the stream once it has been idle for longer than
+// idleTimeout. It returns when the stream is deregistered.
+func (s *Stream) watchIdle() {
+	timer := time.NewTimer(s.idleTimeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-timer.C:
+			idle := time.Since(time.Unix(0, s.lastActivity.Load()))
+			if idle >= s.idleTimeout {
+				log.Debugf("closing idle stream %d (%s) after %s", s.id, s.Protocol, idle)
+				s.reset()
+				return
+			}
+			timer.Reset(s.idleTimeout - idle)
+		}
+	}
+}
+
+type activityReader struct {
+	r io.Reader
+	s *Stream
+}
+
+func (a *activityReader) Read(p []byte) (int, error) {
+	n, err := a.r.Read(p)
+	if n > 0 {
+		a.s.lastActivity.Store(time.Now().UnixNano())
+	}
+	return n, err
 }
 
 // StreamRegistry is a collection of active incoming and outgoing proto app streams.
@@ -80,6 +136,8 @@ func (r *StreamRegistry) Register(streamInfo *Stream) {
 	r.conns[streamInfo.peer]++
 
 	streamInfo.id = r.nextID
+	streamInfo.done = make(chan struct{})
+	streamInfo.lastActivity.Store(time.Now().UnixNano())
 	r.Streams[r.nextID] = streamInfo
 	r.nextID++
 
@@ -103,6 +161,7 @@ func (r *StreamRegistry) Deregister(streamID uint64) {
 	}
 
 	delete(r.Streams, streamID)
+	close(s.done)
 }
 
 // Close stream endpoints and deregister it.
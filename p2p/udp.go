@@ -0,0 +1,400 @@
+package p2p
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tec "github.com/jbenet/go-temp-err-catcher"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// UDP tunnels carry datagrams over libp2p streams. Each datagram is prefixed
+// with its length as a 2 byte big-endian integer, so datagrams are limited to
+// maxDatagramSize bytes.
+const (
+	datagramHeaderSize = 2
+	maxDatagramSize    = math.MaxUint16
+
+	// udpSessionQueue is how many datagrams from a local UDP peer are
+	// buffered while its stream is being set up or is backed up. Datagrams
+	// received while the queue is full are dropped.
+	udpSessionQueue = 64
+)
+
+const (
+	// DefaultUDPIdleTimeout is the idle timeout of UDP tunnels. UDP has no
+	// notion of a closed connection, so streams of local peers that went
+	// away are only reclaimed by the timeout.
+	DefaultUDPIdleTimeout = time.Minute
+
+	// DefaultMaxUDPSessions is the number of local UDP peers a
+	// ForwardLocalUDP listener keeps a stream open for.
+	DefaultMaxUDPSessions = 256
+)
+
+var udpForwardDefaults = forwardOptions{
+	idleTimeout: DefaultUDPIdleTimeout,
+	maxSessions: DefaultMaxUDPSessions,
+}
+
+// IsUDPAddr returns whether addr is a plain UDP address, suitable for UDP
+// tunnels.
+func IsUDPAddr(addr ma.Multiaddr) bool {
+	var last ma.Component
+	ma.ForEach(addr, func(c ma.Component) bool {
+		last = c
+		return true
+	})
+	return last.Protocol().Code == ma.P_UDP
+}
+
+// framedConn turns a manet.Conn reading and writing whole datagrams into a
+// byte stream of length-prefixed datagrams, and back.
+type framedConn struct {
+	manet.Conn
+
+	readBuf []byte
+	pending []byte
+	written []byte
+}
+
+func newFramedConn(c manet.Conn) *framedConn {
+	return &framedConn{
+		Conn:    c,
+		readBuf: make([]byte, datagramHeaderSize+maxDatagramSize),
+	}
+}
+
+// Read returns the next framed datagram, or what is left of it.
+func (c *framedConn) Read(p []byte) (int, error) {
+	if len(c.pending) == 0 {
+		n, err := c.Conn.Read(c.readBuf[datagramHeaderSize:])
+		if err != nil {
+			return 0, err
+		}
+		binary.BigEndian.PutUint16(c.readBuf, uint16(n))
+		c.pending = c.readBuf[:datagramHeaderSize+n]
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// Write sends every complete frame in p as a datagram, incomplete frames are
+// kept until the rest of them is written.
+func (c *framedConn) Write(p []byte) (int, error) {
+	c.written = append(c.written, p...)
+	for len(c.written) >= datagramHeaderSize {
+		size := datagramHeaderSize + int(binary.BigEndian.Uint16(c.written))
+		if len(c.written) < size {
+			break
+		}
+		if _, err := c.Conn.Write(c.written[datagramHeaderSize:size]); err != nil {
+			return 0, err
+		}
+		c.written = c.written[size:]
+	}
+	// Don't keep growing the backing array of a mostly consumed buffer.
+	if len(c.written) == 0 {
+		c.written = c.written[:0:0]
+	}
+	return len(p), nil
+}
+
+// localPacketListener receives UDP datagrams and proxies them to libp2p
+// services, one stream per local UDP peer.
+type localPacketListener struct {
+	ctx context.Context
+
+	p2p *P2P
+
+	proto protocol.ID
+	laddr ma.Multiaddr
+	peer  peer.ID
+
+	idleTimeout time.Duration
+	retry       DialRetry
+	maxSessions int
+
+	conn manet.PacketConn
+
+	lk       sync.Mutex
+	sessions map[string]*udpSession
+}
+
+// ForwardLocalUDP creates a new UDP tunnel to a remote listener forwarding
+// to a UDP target. Datagrams received on bindAddr are forwarded over one
+// stream per sender. Unlike ForwardLocal, streams are reset after
+// DefaultUDPIdleTimeout and at most DefaultMaxUDPSessions senders are served
+// at once, unless configured otherwise.
+func (p2p *P2P) ForwardLocalUDP(ctx context.Context, peer peer.ID, proto protocol.ID, bindAddr ma.Multiaddr, opts ...ForwardOption) (Listener, error) {
+	if !IsUDPAddr(bindAddr) {
+		return nil, fmt.Errorf("%s is not a udp address", bindAddr)
+	}
+
+	o := processForwardOptions(udpForwardDefaults, opts)
+	listener := &localPacketListener{
+		ctx:         ctx,
+		p2p:         p2p,
+		proto:       proto,
+		peer:        peer,
+		idleTimeout: o.idleTimeout,
+		retry:       o.retry,
+		maxSessions: o.maxSessions,
+		sessions:    map[string]*udpSession{},
+	}
+
+	conn, err := manet.ListenPacket(bindAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	listener.conn = conn
+	listener.laddr = conn.LocalMultiaddr()
+
+	if err := p2p.ListenersLocal.Register(listener); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	go listener.acceptPackets()
+
+	return listener, nil
+}
+
+func (l *localPacketListener) acceptPackets() {
+	defer l.closeSessions()
+
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, addr, err := l.conn.ReadFrom(buf)
+		if err != nil {
+			if tec.ErrIsTemporary(err) {
+				continue
+			}
+			return
+		}
+
+		l.lk.Lock()
+		var evicted *udpSession
+		session, ok := l.sessions[addr.String()]
+		if !ok {
+			session, err = l.newSession(addr)
+			if err != nil {
+				l.lk.Unlock()
+				log.Warnf("failed to start udp session for %s: %s", addr, err)
+				continue
+			}
+			if l.maxSessions > 0 && len(l.sessions) >= l.maxSessions {
+				evicted = l.leastActiveSession()
+				delete(l.sessions, evicted.addr.String())
+			}
+			l.sessions[addr.String()] = session
+			go l.setupStream(session)
+		}
+		l.lk.Unlock()
+
+		if evicted != nil {
+			log.Debugf("closing udp session for %s, too many sessions", evicted.addr)
+			evicted.Close()
+		}
+		session.deliver(buf[:n])
+	}
+}
+
+func (l *localPacketListener) newSession(addr net.Addr) (*udpSession, error) {
+	raddr, err := manet.FromNetAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	session := &udpSession{
+		listener: l,
+		addr:     addr,
+		raddr:    raddr,
+		incoming: make(chan []byte, udpSessionQueue),
+		closed:   make(chan struct{}),
+	}
+	session.lastActivity.Store(time.Now().UnixNano())
+	return session, nil
+}
+
+// leastActiveSession returns the session that exchanged a datagram the
+// longest time ago. l.lk must be held and there must be a session.
+func (l *localPacketListener) leastActiveSession() *udpSession {
+	var oldest *udpSession
+	for _, session := range l.sessions {
+		if oldest == nil || session.lastActivity.Load() < oldest.lastActivity.Load() {
+			oldest = session
+		}
+	}
+	return oldest
+}
+
+func (l *localPacketListener) setupStream(session *udpSession) {
+	remote, err := l.p2p.newStream(l.ctx, l.peer, l.proto, l.retry)
+	if err != nil {
+		session.Close()
+		log.Warnf("failed to dial to remote %s/%s: %s", l.peer, l.proto, err)
+		return
+	}
+
+	stream := &Stream{
+		Protocol: l.proto,
+
+		OriginAddr: session.raddr,
+		TargetAddr: l.TargetAddress(),
+		peer:       l.peer,
+
+		Local:  newFramedConn(session),
+		Remote: remote,
+
+		Registry: l.p2p.Streams,
+
+		idleTimeout: l.idleTimeout,
+	}
+
+	l.p2p.Streams.Register(stream)
+}
+
+func (l *localPacketListener) removeSession(session *udpSession) {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	if l.sessions[session.addr.String()] == session {
+		delete(l.sessions, session.addr.String())
+	}
+}
+
+// closeSessions ends all sessions once the listener is closed, as they can't
+// receive datagrams anymore.
+func (l *localPacketListener) closeSessions() {
+	l.lk.Lock()
+	sessions := make([]*udpSession, 0, len(l.sessions))
+	for _, session := range l.sessions {
+		sessions = append(sessions, session)
+	}
+	l.lk.Unlock()
+
+	for _, session := range sessions {
+		session.Close()
+	}
+}
+
+func (l *localPacketListener) close() {
+	l.conn.Close()
+}
+
+func (l *localPacketListener) Protocol() protocol.ID {
+	return l.proto
+}
+
+func (l *localPacketListener) ListenAddress() ma.Multiaddr {
+	return l.laddr
+}
+
+func (l *localPacketListener) TargetAddress() ma.Multiaddr {
+	addr, err := ma.NewMultiaddr(maPrefix + l.peer.String())
+	if err != nil {
+		panic(err)
+	}
+	return addr
+}
+
+func (l *localPacketListener) key() protocol.ID {
+	return protocol.ID(l.ListenAddress().String())
+}
+
+// udpSession is the manet.Conn for datagrams exchanged with a single local
+// UDP peer. Each Read returns one datagram and each Write sends one.
+type udpSession struct {
+	listener *localPacketListener
+
+	addr  net.Addr
+	raddr ma.Multiaddr
+
+	incoming  chan []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	// lastActivity is when a datagram was last received from or sent to
+	// the peer, in Unix nanoseconds.
+	lastActivity atomic.Int64
+}
+
+var _ manet.Conn = (*udpSession)(nil)
+
+// deliver queues a copy of datagram for reading, dropping it if the queue is
+// full.
+func (s *udpSession) deliver(datagram []byte) {
+	s.lastActivity.Store(time.Now().UnixNano())
+	select {
+	case s.incoming <- append([]byte(nil), datagram...):
+	case <-s.closed:
+	default:
+		log.Debugf("dropping datagram from %s, queue full", s.addr)
+	}
+}
+
+func (s *udpSession) Read(p []byte) (int, error) {
+	select {
+	case datagram := <-s.incoming:
+		if len(datagram) > len(p) {
+			return 0, io.ErrShortBuffer
+		}
+		return copy(p, datagram), nil
+	case <-s.closed:
+		return 0, io.EOF
+	}
+}
+
+func (s *udpSession) Write(p []byte) (int, error) {
+	select {
+	case <-s.closed:
+		return 0, net.ErrClosed
+	default:
+	}
+	s.lastActivity.Store(time.Now().UnixNano())
+	return s.listener.conn.WriteTo(p, s.addr)
+}
+
+func (s *udpSession) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+		s.listener.removeSession(s)
+	})
+	return nil
+}
+
+func (s *udpSession) LocalAddr() net.Addr {
+	return s.listener.conn.LocalAddr()
+}
+
+func (s *udpSession) RemoteAddr() net.Addr {
+	return s.addr
+}
+
+func (s *udpSession) LocalMultiaddr() ma.Multiaddr {
+	return s.listener.laddr
+}
+
+func (s *udpSession) RemoteMultiaddr() ma.Multiaddr {
+	return s.raddr
+}
+
+// Deadlines are not supported, blocked reads are interrupted by Close.
+var errDeadlinesNotSupported = errors.New("udp session does not support deadlines")
+
+func (s *udpSession) SetDeadline(time.Time) error      { return errDeadlinesNotSupported }
+func (s *udpSession) SetReadDeadline(time.Time) error  { return errDeadlinesNotSupported }
+func (s *udpSession) SetWriteDeadline(time.Time) error { return errDeadlinesNotSupported }
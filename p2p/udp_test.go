@@ -0,0 +1,255 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// datagramConn is a manet.Conn exchanging whole datagrams through slices.
+type datagramConn struct {
+	manet.Conn
+
+	toRead  [][]byte
+	written [][]byte
+}
+
+func (c *datagramConn) Read(p []byte) (int, error) {
+	if len(c.toRead) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, c.toRead[0])
+	c.toRead = c.toRead[1:]
+	return n, nil
+}
+
+func (c *datagramConn) Write(p []byte) (int, error) {
+	c.written = append(c.written, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func TestFramedConn(t *testing.T) {
+	dc := &datagramConn{toRead: [][]byte{[]byte("hello"), {}, []byte("world!")}}
+	fc := newFramedConn(dc)
+
+	// Reads smaller than a frame return it piece by piece.
+	var stream []byte
+	buf := make([]byte, 3)
+	for {
+		n, err := fc.Read(buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		stream = append(stream, buf[:n]...)
+	}
+	expected := []byte("\x00\x05hello\x00\x00\x00\x06world!")
+	if !bytes.Equal(stream, expected) {
+		t.Fatalf("expected %q, got %q", expected, stream)
+	}
+
+	// Writing the stream back, split at every possible offset, sends the
+	// same datagrams.
+	for split := 0; split <= len(stream); split++ {
+		dc.written = nil
+		for _, chunk := range [][]byte{stream[:split], stream[split:]} {
+			if n, err := fc.Write(chunk); err != nil || n != len(chunk) {
+				t.Fatalf("split %d: wrote %d/%d bytes: %v", split, n, len(chunk), err)
+			}
+		}
+		if len(dc.written) != 3 || string(dc.written[0]) != "hello" || len(dc.written[1]) != 0 || string(dc.written[2]) != "world!" {
+			t.Fatalf("split %d: unexpected datagrams %q", split, dc.written)
+		}
+	}
+
+	// Incomplete frames are held back.
+	dc.written = nil
+	if _, err := fc.Write([]byte("\x00\x05hel")); err != nil {
+		t.Fatal(err)
+	}
+	if len(dc.written) != 0 {
+		t.Fatalf("expected no datagram for an incomplete frame, got %q", dc.written)
+	}
+}
+
+func TestUDPTunnel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn, err := mocknet.FullMeshConnected(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts := mn.Hosts()
+	client := New(hosts[0].ID(), hosts[0], hosts[0].Peerstore())
+	server := New(hosts[1].ID(), hosts[1], hosts[1].Peerstore())
+
+	if _, err := server.ForwardRemoteUDP(ctx, "/x/udp", udpEchoTarget(t), false); err != nil {
+		t.Fatal(err)
+	}
+	l, err := client.ForwardLocalUDP(ctx, hosts[1].ID(), "/x/udp", ma.StringCast("/ip4/127.0.0.1/udp/0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bindAddr, err := manet.ToNetAddr(l.ListenAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each local peer gets its own session and only sees its own replies.
+	var peers []*net.UDPConn
+	for i := 0; i < 2; i++ {
+		c, err := net.DialUDP("udp4", nil, bindAddr.(*net.UDPAddr))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		peers = append(peers, c)
+	}
+
+	for _, msg := range []string{"ping", "", "pong"} {
+		for i, c := range peers {
+			udpExchange(t, c, msg+string(rune('a'+i)))
+		}
+	}
+
+	if n := streamCount(client); n != 2 {
+		t.Fatalf("expected 2 streams on the client, got %d", n)
+	}
+
+	// Closing the listener ends the sessions and their streams.
+	client.ListenersLocal.Close(func(Listener) bool { return true })
+	waitFor(t, "the streams to be closed", func() bool { return streamCount(client) == 0 })
+}
+
+func TestUDPTunnelSessions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mn, err := mocknet.FullMeshConnected(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts := mn.Hosts()
+	client := New(hosts[0].ID(), hosts[0], hosts[0].Peerstore())
+	server := New(hosts[1].ID(), hosts[1], hosts[1].Peerstore())
+
+	if _, err := server.ForwardRemoteUDP(ctx, "/x/udp", udpEchoTarget(t), false); err != nil {
+		t.Fatal(err)
+	}
+	l, err := client.ForwardLocalUDP(ctx, hosts[1].ID(), "/x/udp", ma.StringCast("/ip4/127.0.0.1/udp/0"),
+		WithIdleTimeout(500*time.Millisecond), WithMaxUDPSessions(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := l.(*localPacketListener)
+	bindAddr, err := manet.ToNetAddr(l.ListenAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A third peer takes the session of the least recently active one.
+	var peers []*net.UDPConn
+	for i := 0; i < 3; i++ {
+		c, err := net.DialUDP("udp4", nil, bindAddr.(*net.UDPAddr))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		peers = append(peers, c)
+	}
+	udpExchange(t, peers[0], "a")
+	udpExchange(t, peers[1], "b")
+	udpExchange(t, peers[0], "a")
+	udpExchange(t, peers[2], "c")
+
+	listener.lk.Lock()
+	_, kept := listener.sessions[peers[0].LocalAddr().String()]
+	_, evicted := listener.sessions[peers[1].LocalAddr().String()]
+	sessions := len(listener.sessions)
+	listener.lk.Unlock()
+	if sessions != 2 || !kept || evicted {
+		t.Fatalf("expected the sessions of the two most recently active peers, got %d sessions (first kept: %t, second kept: %t)", sessions, kept, evicted)
+	}
+	waitFor(t, "the evicted stream to be closed", func() bool { return streamCount(client) == 2 })
+
+	// Idle sessions are reaped along with their streams.
+	waitFor(t, "the idle sessions to be closed", func() bool {
+		listener.lk.Lock()
+		defer listener.lk.Unlock()
+		return len(listener.sessions) == 0 && streamCount(client) == 0
+	})
+
+	// And a peer coming back gets a new one.
+	udpExchange(t, peers[1], "b")
+}
+
+// udpEchoTarget returns the address of a UDP server answering every datagram
+// with its uppercase version.
+func udpEchoTarget(t *testing.T) ma.Multiaddr {
+	target, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { target.Close() })
+	go func() {
+		buf := make([]byte, maxDatagramSize)
+		for {
+			n, addr, err := target.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			_, _ = target.WriteTo(bytes.ToUpper(buf[:n]), addr)
+		}
+	}()
+	addr, err := manet.FromNetAddr(target.LocalAddr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return addr
+}
+
+// udpExchange sends msg through c and checks that udpEchoTarget answers.
+func udpExchange(t *testing.T, c *net.UDPConn, msg string) {
+	t.Helper()
+	if _, err := c.Write([]byte(msg)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, maxDatagramSize)
+	n, err := c.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := bytes.ToUpper([]byte(msg)); !bytes.Equal(buf[:n], expected) {
+		t.Fatalf("expected %q, got %q", expected, buf[:n])
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func streamCount(p *P2P) int {
+	p.Streams.Lock()
+	defer p.Streams.Unlock()
+	return len(p.Streams.Streams)
+}
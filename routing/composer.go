@@ -3,6 +3,8 @@ package routing
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sync"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/ipfs/go-cid"
@@ -10,6 +12,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/routing"
 	"github.com/multiformats/go-multihash"
+	"golang.org/x/sync/errgroup"
 )
 
 var (
@@ -37,13 +40,7 @@ func (c *Composer) Provide(ctx context.Context, cid cid.Cid, provide bool) error
 
 func (c *Composer) ProvideMany(ctx context.Context, keys []multihash.Multihash) error {
 	log.Debug("composer: calling provide many: ", len(keys))
-	pmr, ok := c.ProvideRouter.(routinghelpers.ProvideManyRouter)
-	if !ok {
-		log.Debug("composer: provide many is not implemented on the actual router")
-		return nil
-	}
-
-	err := pmr.ProvideMany(ctx, keys)
+	err := provideMany(ctx, c.ProvideRouter, keys)
 	if err != nil {
 		log.Debug("composer: calling provide many error: ", err)
 	}
@@ -51,6 +48,41 @@ func (c *Composer) ProvideMany(ctx context.Context, keys []multihash.Multihash)
 	return err
 }
 
+// provideManyConcurrency bounds the number of concurrent Provide calls made
+// by provideMany for routers without bulk provide support.
+const provideManyConcurrency = 16
+
+// provideMany announces keys through r in one ProvideMany call when r
+// supports it. Otherwise every key is announced with Provide, up to
+// provideManyConcurrency at a time. A failed Provide does not stop the other
+// keys from being announced, all the failures are returned together.
+func provideMany(ctx context.Context, r routing.Routing, keys []multihash.Multihash) error {
+	if pmr, ok := r.(routinghelpers.ProvideManyRouter); ok {
+		return pmr.ProvideMany(ctx, keys)
+	}
+
+	log.Debug("composer: provide many is not implemented on the actual router, providing keys one by one")
+	var (
+		g    errgroup.Group
+		mu   sync.Mutex
+		errs *multierror.Error
+	)
+	g.SetLimit(provideManyConcurrency)
+	for _, k := range keys {
+		g.Go(func() error {
+			c := cid.NewCidV1(cid.Raw, k)
+			if err := r.Provide(ctx, c, true); err != nil {
+				mu.Lock()
+				errs = multierror.Append(errs, fmt.Errorf("providing %s: %w", c, err))
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+	_ = g.Wait()
+	return errs.ErrorOrNil()
+}
+
 func (c *Composer) Ready() bool {
 	log.Debug("composer: calling ready")
 	pmr, ok := c.ProvideRouter.(routinghelpers.ReadyAbleRouter)
@@ -0,0 +1,72 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	routinghelpers "github.com/libp2p/go-libp2p-routing-helpers"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+// provideRouter records the keys it is asked to provide, one at a time. Keys
+// in fail are not provided and return an error.
+type provideRouter struct {
+	routinghelpers.Null
+
+	mu       sync.Mutex
+	provided []cid.Cid
+	fail     map[cid.Cid]bool
+}
+
+func (r *provideRouter) Provide(ctx context.Context, c cid.Cid, announce bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fail[c] {
+		return errProvideFailed
+	}
+	r.provided = append(r.provided, c)
+	return nil
+}
+
+var errProvideFailed = errors.New("provide failed")
+
+func TestComposerProvideManyFallback(t *testing.T) {
+	ctx := context.Background()
+
+	var keys []multihash.Multihash
+	for i := 0; i < 3*provideManyConcurrency; i++ {
+		mh, err := multihash.Sum([]byte{byte(i)}, multihash.SHA2_256, -1)
+		require.NoError(t, err)
+		keys = append(keys, mh)
+	}
+
+	r := &provideRouter{}
+	c := &Composer{ProvideRouter: r}
+	require.NoError(t, c.ProvideMany(ctx, keys))
+
+	var want []cid.Cid
+	for _, k := range keys {
+		want = append(want, cid.NewCidV1(cid.Raw, k))
+	}
+	require.ElementsMatch(t, want, r.provided)
+
+	// A timeout wrapper must not hide the lack of bulk provide support.
+	r = &provideRouter{}
+	c = &Composer{ProvideRouter: &timeoutRouter{router: r, timeout: time.Minute}}
+	require.NoError(t, c.ProvideMany(ctx, keys))
+	require.ElementsMatch(t, want, r.provided)
+
+	// Failures don't stop the other keys from being provided.
+	r = &provideRouter{fail: map[cid.Cid]bool{want[0]: true, want[len(want)-1]: true}}
+	c = &Composer{ProvideRouter: r}
+	err := c.ProvideMany(ctx, keys)
+	require.ErrorIs(t, err, errProvideFailed)
+	require.ErrorContains(t, err, want[0].String())
+	require.ErrorContains(t, err, want[len(want)-1].String())
+	require.ElementsMatch(t, want[1:len(want)-1], r.provided)
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	drclient "github.com/ipfs/boxo/routing/http/client"
 	"github.com/ipfs/boxo/routing/http/contentrouter"
@@ -38,11 +39,19 @@ func Parse(routers config.Routers, methods config.Methods, extraDHT *ExtraDHTPar
 
 	// Create all needed routers from method names
 	for mn, m := range methods {
+		if err := checkMethodAllowed(make(map[string]bool), mn, m.RouterName, routers); err != nil {
+			return nil, err
+		}
+
 		router, err := parse(make(map[string]bool), createdRouters, m.RouterName, routers, extraDHT, extraHTTP)
 		if err != nil {
 			return nil, err
 		}
 
+		if timeout := m.Timeout.WithDefault(0); timeout > 0 {
+			router = &timeoutRouter{router: router, timeout: timeout}
+		}
+
 		switch mn {
 		case config.MethodNamePutIPNS:
 			finalRouter.PutValueRouter = router
@@ -62,6 +71,36 @@ func Parse(routers config.Routers, methods config.Methods, extraDHT *ExtraDHTPar
 	return finalRouter, nil
 }
 
+// checkMethodAllowed makes sure routerName, and every router it is composed
+// of, accepts being used for the method mn.
+func checkMethodAllowed(visited map[string]bool, mn config.MethodName, routerName string, routersCfg config.Routers) error {
+	if visited[routerName] {
+		// dependency loops are reported by parse
+		return nil
+	}
+	visited[routerName] = true
+
+	cfg, ok := routersCfg[routerName]
+	if !ok {
+		// missing routers are reported by parse
+		return nil
+	}
+
+	if !cfg.AllowsMethod(mn) {
+		return fmt.Errorf("router %q is not allowed to be used for method %q", routerName, mn)
+	}
+
+	if crp, ok := cfg.Parameters.(*config.ComposableRouterParams); ok {
+		for _, cr := range crp.Routers {
+			if err := checkMethodAllowed(visited, mn, cr.RouterName, routersCfg); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
 func parse(visited map[string]bool,
 	createdRouters map[string]routing.Routing,
 	routerName string,
@@ -178,6 +217,12 @@ func httpRoutingFromConfig(conf config.Router, extraHTTP *ExtraHTTPParams) (rout
 
 	params.FillDefaults()
 
+	if conf.CheckOnStartup {
+		if err := checkHTTPEndpoint(params.Endpoint); err != nil {
+			return nil, err
+		}
+	}
+
 	// Increase per-host connection pool since we are making lots of concurrent requests.
 	transport := http.DefaultTransport.(*http.Transport).Clone()
 	transport.MaxIdleConns = 500
@@ -230,6 +275,31 @@ func httpRoutingFromConfig(conf config.Router, extraHTTP *ExtraHTTPParams) (rout
 	}, nil
 }
 
+// httpEndpointCheckTimeout bounds the startup check of HTTP routers so a
+// misbehaving endpoint cannot hang the daemon.
+const httpEndpointCheckTimeout = 5 * time.Second
+
+// checkHTTPEndpoint makes sure the endpoint answers HTTP requests. Any
+// response, whatever its status code, is good enough: the goal is to catch
+// unreachable or misspelled endpoints early.
+func checkHTTPEndpoint(endpoint string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), httpEndpointCheckTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("invalid delegated routing endpoint %q: %w", endpoint, err)
+	}
+	req.Header.Set("User-Agent", version.GetUserAgentVersion())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("delegated routing endpoint %q is unreachable: %w", endpoint, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
 func decodePrivKey(keyB64 string) (ic.PrivKey, error) {
 	pk, err := base64.StdEncoding.DecodeString(keyB64)
 	if err != nil {
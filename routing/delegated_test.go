@@ -3,6 +3,9 @@ package routing
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/ipfs/kubo/config"
@@ -205,6 +208,125 @@ func TestParserRecursiveLoop(t *testing.T) {
 	require.ErrorContains(err, "dependency loop creating router with name \"composable2\"")
 }
 
+func TestParserMethodRestrictions(t *testing.T) {
+	pid, sk, err := generatePeerID()
+	require.NoError(t, err)
+
+	routers := func(indexer config.Router) config.Routers {
+		return config.Routers{
+			"indexer": config.RouterParser{Router: indexer},
+			"composable": config.RouterParser{
+				Router: config.Router{
+					Type: config.RouterTypeParallel,
+					Parameters: &config.ComposableRouterParams{
+						Routers: []config.ConfigRouter{
+							{
+								RouterName: "indexer",
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+	methods := config.Methods{
+		config.MethodNameFindPeers:     config.Method{RouterName: "indexer"},
+		config.MethodNameFindProviders: config.Method{RouterName: "indexer"},
+		config.MethodNameGetIPNS:       config.Method{RouterName: "indexer"},
+		config.MethodNamePutIPNS:       config.Method{RouterName: "indexer"},
+		config.MethodNameProvide:       config.Method{RouterName: "composable"},
+	}
+	extraHTTP := &ExtraHTTPParams{
+		PeerID:     pid,
+		PrivKeyB64: sk,
+	}
+
+	t.Run("denied method through composable router", func(t *testing.T) {
+		_, err := Parse(routers(config.Router{
+			Type:          config.RouterTypeHTTP,
+			Parameters:    &config.HTTPRouterParams{Endpoint: "testEndpoint"},
+			DeniedMethods: []config.MethodName{config.MethodNameProvide},
+		}), methods, &ExtraDHTParams{}, extraHTTP)
+		require.ErrorContains(t, err, "router \"indexer\" is not allowed to be used for method \"provide\"")
+	})
+
+	t.Run("method missing from allow list", func(t *testing.T) {
+		_, err := Parse(routers(config.Router{
+			Type:           config.RouterTypeHTTP,
+			Parameters:     &config.HTTPRouterParams{Endpoint: "testEndpoint"},
+			AllowedMethods: []config.MethodName{config.MethodNameFindProviders, config.MethodNameFindPeers},
+		}), methods, &ExtraDHTParams{}, extraHTTP)
+		require.ErrorContains(t, err, "router \"indexer\" is not allowed to be used for method")
+	})
+
+	t.Run("allowed methods", func(t *testing.T) {
+		rs := routers(config.Router{
+			Type:          config.RouterTypeHTTP,
+			Parameters:    &config.HTTPRouterParams{Endpoint: "testEndpoint"},
+			DeniedMethods: []config.MethodName{config.MethodNameProvide},
+		})
+		rs["writer"] = config.RouterParser{
+			Router: config.Router{
+				Type:       config.RouterTypeHTTP,
+				Parameters: &config.HTTPRouterParams{Endpoint: "testEndpoint2"},
+			},
+		}
+		_, err := Parse(rs, config.Methods{
+			config.MethodNameFindPeers:     config.Method{RouterName: "indexer"},
+			config.MethodNameFindProviders: config.Method{RouterName: "indexer"},
+			config.MethodNameGetIPNS:       config.Method{RouterName: "indexer"},
+			config.MethodNamePutIPNS:       config.Method{RouterName: "writer"},
+			config.MethodNameProvide:       config.Method{RouterName: "writer"},
+		}, &ExtraDHTParams{}, extraHTTP)
+		require.NoError(t, err)
+	})
+}
+
+func TestParserCheckOnStartup(t *testing.T) {
+	pid, sk, err := generatePeerID()
+	require.NoError(t, err)
+
+	parseWithEndpoint := func(endpoint string) error {
+		_, err := Parse(config.Routers{
+			"r1": config.RouterParser{
+				Router: config.Router{
+					Type: config.RouterTypeHTTP,
+					Parameters: &config.HTTPRouterParams{
+						Endpoint: endpoint,
+					},
+					CheckOnStartup: true,
+				},
+			},
+		}, config.Methods{
+			config.MethodNameFindPeers:     config.Method{RouterName: "r1"},
+			config.MethodNameFindProviders: config.Method{RouterName: "r1"},
+			config.MethodNameGetIPNS:       config.Method{RouterName: "r1"},
+			config.MethodNamePutIPNS:       config.Method{RouterName: "r1"},
+			config.MethodNameProvide:       config.Method{RouterName: "r1"},
+		}, &ExtraDHTParams{}, &ExtraHTTPParams{
+			PeerID:     pid,
+			PrivKeyB64: sk,
+		})
+		return err
+	}
+
+	t.Run("reachable endpoint", func(t *testing.T) {
+		srv := httptest.NewServer(http.NotFoundHandler())
+		defer srv.Close()
+
+		require.NoError(t, parseWithEndpoint(srv.URL))
+	})
+
+	t.Run("unreachable endpoint", func(t *testing.T) {
+		srv := httptest.NewServer(http.NotFoundHandler())
+		endpoint := srv.URL
+		srv.Close()
+
+		err := parseWithEndpoint(endpoint)
+		require.ErrorContains(t, err, fmt.Sprintf("delegated routing endpoint %q is unreachable", endpoint))
+	})
+}
+
 func generatePeerID() (string, string, error) {
 	sk, pk, err := crypto.GenerateEd25519Key(rand.Reader)
 	if err != nil {
@@ -2,9 +2,13 @@ package routing
 
 import (
 	"context"
+	"time"
 
+	"github.com/ipfs/go-cid"
 	routinghelpers "github.com/libp2p/go-libp2p-routing-helpers"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/routing"
+	"github.com/multiformats/go-multihash"
 )
 
 type ProvideManyRouter interface {
@@ -29,3 +33,149 @@ type httpRoutingWrapper struct {
 func (c *httpRoutingWrapper) Bootstrap(ctx context.Context) error {
 	return nil
 }
+
+var (
+	_ routing.Routing                  = &timeoutRouter{}
+	_ routinghelpers.ProvideManyRouter = &timeoutRouter{}
+	_ routinghelpers.ReadyAbleRouter   = &timeoutRouter{}
+)
+
+// timeoutRouter bounds every call to the wrapped router with a deadline, as
+// configured per method in Routing.Methods. Calls return as soon as the
+// deadline passes, even if the wrapped router does not honor the context.
+type timeoutRouter struct {
+	router  routing.Routing
+	timeout time.Duration
+}
+
+func (r *timeoutRouter) Provide(ctx context.Context, c cid.Cid, announce bool) error {
+	return r.run(ctx, func(ctx context.Context) error {
+		return r.router.Provide(ctx, c, announce)
+	})
+}
+
+func (r *timeoutRouter) ProvideMany(ctx context.Context, keys []multihash.Multihash) error {
+	return r.run(ctx, func(ctx context.Context) error {
+		return provideMany(ctx, r.router, keys)
+	})
+}
+
+func (r *timeoutRouter) Ready() bool {
+	rar, ok := r.router.(routinghelpers.ReadyAbleRouter)
+	if !ok {
+		return true
+	}
+	return rar.Ready()
+}
+
+func (r *timeoutRouter) FindProvidersAsync(ctx context.Context, c cid.Cid, count int) <-chan peer.AddrInfo {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	in := r.router.FindProvidersAsync(ctx, c, count)
+	out := make(chan peer.AddrInfo)
+	go func() {
+		defer cancel()
+		defer close(out)
+		for {
+			select {
+			case ai, ok := <-in:
+				if !ok {
+					return
+				}
+				select {
+				case out <- ai:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (r *timeoutRouter) FindPeer(ctx context.Context, id peer.ID) (peer.AddrInfo, error) {
+	var ai peer.AddrInfo
+	err := r.run(ctx, func(ctx context.Context) error {
+		var err error
+		ai, err = r.router.FindPeer(ctx, id)
+		return err
+	})
+	if err != nil {
+		return peer.AddrInfo{}, err
+	}
+	return ai, nil
+}
+
+func (r *timeoutRouter) PutValue(ctx context.Context, key string, val []byte, opts ...routing.Option) error {
+	return r.run(ctx, func(ctx context.Context) error {
+		return r.router.PutValue(ctx, key, val, opts...)
+	})
+}
+
+func (r *timeoutRouter) GetValue(ctx context.Context, key string, opts ...routing.Option) ([]byte, error) {
+	var val []byte
+	err := r.run(ctx, func(ctx context.Context) error {
+		var err error
+		val, err = r.router.GetValue(ctx, key, opts...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+func (r *timeoutRouter) SearchValue(ctx context.Context, key string, opts ...routing.Option) (<-chan []byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	in, err := r.router.SearchValue(ctx, key, opts...)
+	if err != nil {
+		cancel()
+		return in, err
+	}
+	out := make(chan []byte)
+	go func() {
+		defer cancel()
+		defer close(out)
+		for {
+			select {
+			case val, ok := <-in:
+				if !ok {
+					return
+				}
+				select {
+				case out <- val:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (r *timeoutRouter) Bootstrap(ctx context.Context) error {
+	return r.router.Bootstrap(ctx)
+}
+
+// run calls f with a context carrying the router deadline and returns when
+// either f does or the deadline passes. Results written by f after the
+// deadline are never read by the caller.
+func (r *timeoutRouter) run(ctx context.Context, f func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- f(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,69 @@
+package routing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	routinghelpers "github.com/libp2p/go-libp2p-routing-helpers"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+	"github.com/stretchr/testify/require"
+)
+
+// slowRouter blocks every call for delay, ignoring the context.
+type slowRouter struct {
+	routinghelpers.Null
+	delay time.Duration
+}
+
+func (r *slowRouter) FindPeer(ctx context.Context, id peer.ID) (peer.AddrInfo, error) {
+	time.Sleep(r.delay)
+	return peer.AddrInfo{ID: id}, nil
+}
+
+func (r *slowRouter) GetValue(ctx context.Context, key string, opts ...routing.Option) ([]byte, error) {
+	time.Sleep(r.delay)
+	return []byte("value"), nil
+}
+
+func (r *slowRouter) FindProvidersAsync(ctx context.Context, c cid.Cid, count int) <-chan peer.AddrInfo {
+	out := make(chan peer.AddrInfo)
+	go func() {
+		defer close(out)
+		time.Sleep(r.delay)
+	}()
+	return out
+}
+
+func TestTimeoutRouter(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("slow calls time out", func(t *testing.T) {
+		r := &timeoutRouter{router: &slowRouter{delay: time.Second}, timeout: 50 * time.Millisecond}
+
+		start := time.Now()
+		_, err := r.FindPeer(ctx, "")
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Less(t, time.Since(start), 500*time.Millisecond)
+
+		start = time.Now()
+		_, err = r.GetValue(ctx, "key")
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Less(t, time.Since(start), 500*time.Millisecond)
+
+		start = time.Now()
+		for range r.FindProvidersAsync(ctx, cid.Cid{}, 0) {
+		}
+		require.Less(t, time.Since(start), 500*time.Millisecond)
+	})
+
+	t.Run("fast calls succeed", func(t *testing.T) {
+		r := &timeoutRouter{router: &slowRouter{}, timeout: time.Second}
+
+		val, err := r.GetValue(ctx, "key")
+		require.NoError(t, err)
+		require.Equal(t, []byte("value"), val)
+	})
+}
@@ -111,6 +111,18 @@ test_expect_success "verbose diff (raw-leaves) added link looks right" '
   test_cmp diff_raw_exp diff_raw_out
 '
 
+test_expect_success "diff --stat added link looks right" '
+  ipfs object diff --stat $A $B > diff_out &&
+  echo "1 added, 0 removed, 0 modified" > diff_exp &&
+  test_cmp diff_exp diff_out
+'
+
+test_expect_success "diff --stat of identical objects looks right" '
+  ipfs object diff --stat $A $A > diff_out &&
+  echo "0 added, 0 removed, 0 modified" > diff_exp &&
+  test_cmp diff_exp diff_out
+'
+
 test_expect_success "diff removed link works" '
   ipfs object diff -v $B $A > diff_out
 '
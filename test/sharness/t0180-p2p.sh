@@ -48,6 +48,12 @@ test_expect_success 'cannot re-register p2p listener' '
   test_must_fail ipfsi 0 p2p listen /x/p2p-test /ip4/127.0.0.1/tcp/10103 2>&1 > listener-stdouterr.log
 '
 
+test_expect_success 're-registering p2p listener reports the protocol' '
+  test_must_fail ipfsi 0 p2p listen /x/p2p-test /ip4/127.0.0.1/tcp/10103 2> actual &&
+  echo "Error: protocol already registered: /x/p2p-test" > expected &&
+  test_cmp expected actual
+'
+
 # Server to client communications
 
 spawn_sending_server() {
@@ -371,6 +377,50 @@ test_expect_success 'C->S Close local listener' '
 
 check_test_ports
 
+# Idle timeout
+
+test_expect_success "'ipfs p2p listen' rejects an invalid --idle-timeout" '
+  test_must_fail ipfsi 0 p2p listen --idle-timeout=forever /x/p2p-idle /ip4/127.0.0.1/tcp/10101
+'
+
+test_expect_success "'ipfs p2p listen --allow' rejects invalid peer IDs" '
+  test_must_fail ipfsi 0 p2p listen --allow=notapeerid /x/p2p-allow /ip4/127.0.0.1/tcp/10101
+'
+
+test_expect_success "'ipfs p2p listen --udp' requires a udp target" '
+  test_must_fail ipfsi 0 p2p listen --udp /x/p2p-udp /ip4/127.0.0.1/tcp/10101
+'
+
+test_expect_success "'ipfs p2p forward --udp' requires a udp listen address" '
+  test_must_fail ipfsi 1 p2p forward --udp /x/p2p-udp /ip4/127.0.0.1/tcp/10102 /p2p/$PEERID_0
+'
+
+test_expect_success "Setup: Idle stream with --idle-timeout" '
+  ma-pipe-unidir --listen --pidFile=listener.pid recv /ip4/127.0.0.1/tcp/10101 &
+
+  ipfsi 0 p2p listen --idle-timeout=1s /x/p2p-idle /ip4/127.0.0.1/tcp/10101 &&
+  ipfsi 1 p2p forward /x/p2p-idle /ip4/127.0.0.1/tcp/10102 /p2p/$PEERID_0 &&
+  ma-pipe-unidir --pidFile=client.pid recv /ip4/127.0.0.1/tcp/10102 &
+
+  test_wait_for_file 30 100ms listener.pid &&
+  test_wait_for_file 30 100ms client.pid &&
+  kill -0 $(cat listener.pid) && kill -0 $(cat client.pid)
+'
+
+test_expect_success "idle stream is closed after --idle-timeout" '
+  go-sleep 3s &&
+  ipfsi 0 p2p stream ls > actual &&
+  test_must_be_empty actual &&
+  [ ! -f listener.pid ] && [ ! -f client.pid ]
+'
+
+test_expect_success "Close idle timeout listeners" '
+  ipfsi 0 p2p close -a &&
+  ipfsi 1 p2p close -a
+'
+
+check_test_ports
+
 test_expect_success 'stop iptb' '
   iptb stop
 '
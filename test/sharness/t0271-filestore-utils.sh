@@ -84,6 +84,42 @@ test_filestore_adds() {
     test_cmp ls_expect_file_order ls_actual
   '
 
+  test_expect_success "'$IPFS_CMD filestore ls --sort=hash' output looks good'" '
+    $IPFS_CMD filestore ls --sort=hash > ls_actual &&
+    test_cmp ls_expect_key_order ls_actual
+  '
+
+  test_expect_success "'$IPFS_CMD filestore ls --sort=path' output looks good'" '
+    $IPFS_CMD filestore ls --sort=path > ls_actual &&
+    test_cmp ls_expect_file_order ls_actual
+  '
+
+  test_expect_success "'$IPFS_CMD filestore ls --sort' rejects unknown orders" '
+    test_must_fail $IPFS_CMD filestore ls --sort=bogus
+  '
+
+  test_expect_success "'$IPFS_CMD filestore ls --min-size --max-size' output looks good'" '
+    $IPFS_CMD filestore ls --sort=path --min-size=5KB --max-size=250KB > ls_actual &&
+    grep -e "somedir/file2 0\$" -e "somedir/file3 786432\$" ls_expect_file_order > ls_expect_size_range &&
+    test_cmp ls_expect_size_range ls_actual
+  '
+
+  test_expect_success "'$IPFS_CMD filestore ls' rejects an empty size range" '
+    test_must_fail $IPFS_CMD filestore ls --min-size=1MB --max-size=1KB
+  '
+
+  test_expect_success "'$IPFS_CMD filestore refcount' output looks good'" '
+    printf "1 somedir/file1\n1 somedir/file2\n4 somedir/file3\n" > refcount_expect &&
+    $IPFS_CMD filestore refcount > refcount_actual &&
+    test_cmp refcount_expect refcount_actual
+  '
+
+  test_expect_success "'$IPFS_CMD filestore refcount --min-refs' output looks good'" '
+    echo "4 somedir/file3" > refcount_expect &&
+    $IPFS_CMD filestore refcount --min-refs=2 > refcount_actual &&
+    test_cmp refcount_expect refcount_actual
+  '
+
   test_expect_success "'$IPFS_CMD filestore ls HASH' works" '
     $IPFS_CMD filestore ls $FILE1_HASH > ls_actual &&
     grep -q somedir/file1 ls_actual
@@ -111,11 +147,55 @@ test_filestore_verify() {
     test_cmp verify_expect_file_order verify_actual
   '
 
+  test_expect_success "'$IPFS_CMD filestore verify --checkpoint' removes checkpoint on completion" '
+    $IPFS_CMD filestore verify --checkpoint=test | LC_ALL=C sort > verify_actual &&
+    test_cmp verify_expect_key_order verify_actual &&
+    test ! -e "$IPFS_PATH/filestore-verify-test.json"
+  '
+
+  test_expect_success "'$IPFS_CMD filestore verify --resume' without a checkpoint starts over" '
+    $IPFS_CMD filestore verify --checkpoint=test --resume | LC_ALL=C sort > verify_actual &&
+    test_cmp verify_expect_key_order verify_actual
+  '
+
+  test_expect_success "'$IPFS_CMD filestore verify --checkpoint' rejects paths" '
+    test_must_fail $IPFS_CMD filestore verify --checkpoint="$(pwd)/verify_checkpoint" 2> verify_err &&
+    grep -q "invalid checkpoint name" verify_err &&
+    test ! -e verify_checkpoint
+  '
+
+  test_expect_success "'$IPFS_CMD filestore verify --resume' requires --checkpoint" '
+    test_must_fail $IPFS_CMD filestore verify --resume
+  '
+
   test_expect_success "'$IPFS_CMD filestore verify HASH' works" '
     $IPFS_CMD filestore verify $FILE1_HASH > verify_actual &&
     grep -q somedir/file1 verify_actual
   '
 
+  test_expect_success "'$IPFS_CMD filestore verify --enc=json' ends with a summary" '
+    $IPFS_CMD filestore verify --enc=json > verify_actual &&
+    OK_COUNT=$(wc -l < verify_expect_key_order | tr -d " ") &&
+    test $(grep -c "\"FilePath\"" verify_actual) -eq $OK_COUNT &&
+    tail -n 1 verify_actual | grep -q "^{\"Summary\":{\"ok\":$OK_COUNT}}$"
+  '
+
+  test_expect_success "'$IPFS_CMD filestore verify PATH' only verifies files below PATH" '
+    grep somedir/file1 verify_expect_key_order > verify_expect_path &&
+    $IPFS_CMD filestore verify "$(pwd)/somedir/file1" | LC_ALL=C sort > verify_actual &&
+    test_cmp verify_expect_path verify_actual
+  '
+
+  test_expect_success "'$IPFS_CMD filestore verify' rejects mixing paths and hashes" '
+    test_must_fail $IPFS_CMD filestore verify "$(pwd)/somedir" $FILE1_HASH 2> verify_err &&
+    grep -q "paths and hashes" verify_err
+  '
+
+  test_expect_success "'$IPFS_CMD filestore verify PATH' rejects --checkpoint" '
+    test_must_fail $IPFS_CMD filestore verify --checkpoint=test "$(pwd)/somedir" 2> verify_err &&
+    grep -q "not with <obj> arguments" verify_err
+  '
+
   test_expect_success "rename a file" '
     mv somedir/file1 somedir/file1.bk
   '
@@ -129,6 +209,18 @@ test_filestore_verify() {
     grep no-file verify_actual | grep -q somedir/file1
   '
 
+  test_expect_success "'$IPFS_CMD filestore ls --missing-blocks' shows file as missing" '
+    $IPFS_CMD filestore ls --missing-blocks > ls_actual &&
+    grep "somedir/file1 0\$" ls_expect_file_order > ls_expect_missing &&
+    test_cmp ls_expect_missing ls_actual
+  '
+
+  test_expect_success "'$IPFS_CMD filestore ls --missing-blocks --quiet' only prints hashes" '
+    $IPFS_CMD filestore ls --missing-blocks --quiet > ls_actual &&
+    echo "$FILE1_HASH" > ls_expect_missing &&
+    test_cmp ls_expect_missing ls_actual
+  '
+
   test_expect_success "move file back" '
     mv somedir/file1.bk somedir/file1
   '
@@ -151,8 +243,50 @@ test_filestore_verify() {
     grep changed verify_actual | grep -q somedir/file3
   '
 
+  test_expect_success "'$IPFS_CMD filestore verify --repair' removes changed blocks" '
+    $IPFS_CMD filestore verify --repair > verify_actual &&
+    grep changed verify_actual | grep -q removed &&
+    $IPFS_CMD filestore verify > verify_actual &&
+    test_must_fail grep changed verify_actual
+  '
+
+  test_expect_success "'$IPFS_CMD filestore verify --repair' rejects unknown statuses" '
+    test_must_fail $IPFS_CMD filestore verify --repair --what=ok
+  '
+
+  test_expect_success "move the backing directory" '
+    mv somedir movedir
+  '
+
+  test_expect_success "'$IPFS_CMD filestore migrate-backing --dry-run' writes nothing" '
+    $IPFS_CMD filestore migrate-backing --dry-run somedir movedir > migrate_actual &&
+    grep -q "somedir/file1 movedir/file1 0" migrate_actual &&
+    $IPFS_CMD filestore verify > verify_actual &&
+    grep no-file verify_actual | grep -q somedir/file1
+  '
+
+  test_expect_success "'$IPFS_CMD filestore migrate-backing' rewrites paths" '
+    $IPFS_CMD filestore migrate-backing "$(pwd)/somedir" "$(pwd)/movedir" > migrate_actual &&
+    grep -q "somedir/file2 movedir/file2 0" migrate_actual &&
+    $IPFS_CMD filestore verify > verify_actual &&
+    test_must_fail grep no-file verify_actual &&
+    grep -q movedir/file1 verify_actual
+  '
+
+  test_expect_success "'$IPFS_CMD filestore migrate-backing' rejects paths outside the root" '
+    test_must_fail $IPFS_CMD filestore migrate-backing movedir /
+  '
+
+  test_expect_success "move the backing directory back" '
+    mv movedir somedir
+  '
+
   # reset the state for the next test
   test_init_dataset
+
+  test_expect_success "re-add dataset after repair" '
+    $IPFS_CMD add --raw-leaves --nocopy -r -Q somedir
+  '
 }
 
 test_filestore_dups() {